@@ -0,0 +1,110 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+)
+
+// Baseline records every migration up to and including the migration with the given ID as applied, without applying
+// any of them. It is used to adopt go-migration on a database whose schema was created by other means. The migrations
+// are recorded with their checksums and marked as baselined, so later calls to Migrate verify them as usual and only
+// apply the migrations after them. Migrations that have already been applied, repeatable migrations and files that
+// Migrate would skip are left out.
+// An error wrapping ErrMigrationNotFound is returned if there is no migration with the given ID.
+func (s *Service) Baseline(upTo string) error {
+	return s.BaselineContext(context.Background(), upTo)
+}
+
+// BaselineContext is like Baseline, but stops waiting for the lock as soon as ctx is cancelled.
+func (s *Service) BaselineContext(ctx context.Context, upTo string) error {
+	if upTo == "" {
+		return fmt.Errorf("%w: empty target", ErrMigrationNotFound)
+	}
+
+	release, err := s.prepare(ctx, &MigrateResult{})
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	return s.baseline(ctx, upTo)
+}
+
+func (s *Service) baseline(ctx context.Context, upTo string) error {
+	appliedMigs, err := s.fetchAppliedMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch applied migrations: %w", err)
+	}
+
+	files, err := s.listMigrations(appliedMigs)
+	if err != nil {
+		return fmt.Errorf("failed to list available migrations: %w", err)
+	}
+
+	if _, ok := files[upTo]; !ok || s.isRepeatable(upTo) {
+		return fmt.Errorf("%w: %s", ErrMigrationNotFound, upTo)
+	}
+
+	// All migrations are recorded in a single transaction, so a failing Baseline can simply be retried.
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	count := 0
+
+	for _, mig := range s.orderedIDs(files) {
+		if _, applied := appliedMigs[mig]; applied || mig > upTo || s.isRepeatable(mig) {
+			continue
+		}
+
+		funcMigration, err := s.shouldApplyFuncMigration(mig)
+		if err != nil {
+			s.rollback(tx)
+
+			return fmt.Errorf("failed to determine if func migration should be applied: %w", err)
+		}
+
+		if funcMigration == nil && !isSQLMigration(mig) {
+			continue
+		}
+
+		c, err := s.migrationChecksum(files[mig])
+		if err != nil {
+			s.rollback(tx)
+
+			return err
+		}
+
+		variant := s.variantOf(mig, files[mig])
+
+		if err := s.insertBaselinedMigration(ctx, tx, c, mig, variant); err != nil {
+			s.rollback(tx)
+
+			return fmt.Errorf("failed to baseline migration %s: %w", mig, err)
+		}
+
+		count++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.logger.Info(fmt.Sprintf("baselined %d migrations up to %s", count, upTo))
+
+	return nil
+}
+
+// insertBaselinedMigration records a migration as applied by Baseline.
+func (s *Service) insertBaselinedMigration(ctx context.Context, db execer, checksum, filename, variant string) error {
+	query := fmt.Sprintf(`insert into %s (id, checksum, variant, baselined) values (%s, %s, %s, %s)`,
+		s.quoteTable(s.migrationTable), s.param(1), s.param(2), s.param(3), s.param(4))
+
+	if _, err := db.ExecContext(ctx, query, filename, checksum, nullString(variant), true); err != nil {
+		return fmt.Errorf("failed to insert baselined migration into migrations table: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Baseline(t *testing.T) {
+	db := openTestDB(t)
+
+	// The schema of the first two migrations was created by hand.
+	_, err := db.Exec("create table users (id int); create table roles (id int);")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	migrations := fstest.MapFS{
+		"migrations/1-users.sql":        {Data: []byte("create table users (id int);")},
+		"migrations/2-roles.sqlite.sql": {Data: []byte("create table roles (id int);")},
+		"migrations/2-roles.sql":        {Data: []byte("create table roles (id integer);")},
+		"migrations/3-funcs.go":         {Data: []byte("package migrations")},
+		"migrations/4-groups.sql":       {Data: []byte("create table groups (id int);")},
+		"migrations/R__view.sql":        {Data: []byte("create view if not exists user_ids as select id from users;")},
+	}
+
+	s := newTestService(t, db, migrations, Config{DialectVariants: true})
+
+	if !assert.NoError(t, s.Baseline("2-roles.sql")) {
+		return
+	}
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 5) {
+		assert.Equal(t, MigrationStatus{ID: "1-users.sql", State: StateApplied, Baselined: true,
+			Checksum: "9102907a4763a264a28937ce21187300", AppliedChecksum: "9102907a4763a264a28937ce21187300",
+			AppliedAt: statuses[0].AppliedAt}, statuses[0])
+		assert.Equal(t, MigrationStatus{ID: "2-roles.sql", State: StateApplied, Baselined: true,
+			Variant: "sqlite", AppliedVariant: "sqlite",
+			Checksum: "23b84a4506a16f7f8f2e06e8d475c0e8", AppliedChecksum: "23b84a4506a16f7f8f2e06e8d475c0e8",
+			AppliedAt: statuses[1].AppliedAt}, statuses[1])
+		assert.Equal(t, StateIgnored, statuses[2].State)
+		assert.Equal(t, StatePending, statuses[3].State)
+		assert.Equal(t, StatePending, statuses[4].State)
+	}
+
+	// Only the migrations after the baseline are applied.
+	res, err := s.MigrateWithResult(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"4-groups.sql", "R__view.sql"}, res.Applied())
+	}
+
+	statuses, err = s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 5) {
+		assert.False(t, statuses[3].Baselined)
+	}
+}
+
+func TestService_Baseline_NotFound(t *testing.T) {
+	s := newTestService(t, openTestDB(t), fstest.MapFS{
+		"migrations/1.sql":      {Data: []byte("create table users (id int);")},
+		"migrations/R__v.sql":   {Data: []byte("create view v as select id from users;")},
+		"migrations/2.sql.orig": {Data: []byte("create table users (id int);")},
+	})
+
+	for _, upTo := range []string{"", "2.sql", "R__v.sql"} {
+		err := s.Baseline(upTo)
+		assert.True(t, errors.Is(err, ErrMigrationNotFound), upTo)
+	}
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 3) {
+		assert.Equal(t, StatePending, statuses[0].State)
+	}
+}
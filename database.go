@@ -13,10 +13,19 @@ type Service struct {
 	db                 *sql.DB
 	migrationTable     string
 	migrationLockTable string
+	schema             string
+	createSchema       bool
 	migrationFolder    string
 	lockTimeoutMinutes int
+	repeatablePrefix   string
+	dialectVariants    bool
 	fs                 fs.FS
 	funcMigrations     map[string]FuncMigration
+	dialect            Dialect
+	locker             Locker
+	lockPolicy         LockPolicyOption
+	wait               WaitOption
+	templateVars       map[string]any
 }
 
 // New returns a new Database instance.
@@ -28,6 +37,7 @@ func New(db *sql.DB, opts ...Option) *Service {
 		migrationLockTable: "migration_lock",
 		migrationFolder:    "db/migrations",
 		lockTimeoutMinutes: 15,
+		repeatablePrefix:   "R__",
 		fs:                 os.DirFS("."),
 		funcMigrations:     map[string]FuncMigration{},
 	}
@@ -60,6 +70,14 @@ type Config struct {
 	// Defaults to "migration_lock".
 	LockTableName string
 
+	// Schema specifies the schema of the migration and lock tables. Table and schema names may only contain letters,
+	// digits, underscores and dollar signs, and must not start with a digit.
+	// Defaults to the current schema of the connection.
+	Schema string
+
+	// CreateSchema makes go-migration create Schema before the migration tables, if it does not exist.
+	CreateSchema bool
+
 	// MigrationFolder specifies the location of migration sql files.
 	// Defaults to "db/migrations".
 	MigrationFolder string
@@ -67,6 +85,16 @@ type Config struct {
 	// LockTimeoutMinutes specifies the lock timeout in minutes.
 	// Defaults to 15.
 	LockTimeoutMinutes int
+
+	// RepeatablePrefix specifies the filename prefix of repeatable migrations, which are applied again whenever they
+	// change.
+	// Defaults to "R__".
+	RepeatablePrefix string
+
+	// DialectVariants makes files named with a dialect before their .sql suffix, like 1-users.postgres.sql, variants
+	// of a single migration, 1-users.sql, of which only the one for the current dialect is applied. Without it, such
+	// files are migrations of their own.
+	DialectVariants bool
 }
 
 func (c Config) apply(service *Service) {
@@ -78,6 +106,14 @@ func (c Config) apply(service *Service) {
 		service.migrationLockTable = c.LockTableName
 	}
 
+	if c.Schema != "" {
+		service.schema = c.Schema
+	}
+
+	if c.CreateSchema {
+		service.createSchema = true
+	}
+
 	if c.MigrationFolder != "" {
 		service.migrationFolder = c.MigrationFolder
 	}
@@ -85,6 +121,14 @@ func (c Config) apply(service *Service) {
 	if c.LockTimeoutMinutes > 0 {
 		service.lockTimeoutMinutes = c.LockTimeoutMinutes
 	}
+
+	if c.RepeatablePrefix != "" {
+		service.repeatablePrefix = c.RepeatablePrefix
+	}
+
+	if c.DialectVariants {
+		service.dialectVariants = true
+	}
 }
 
 // FSOption makes migration use a specific FileSystem, instead of the default.
@@ -111,3 +155,15 @@ type FuncMigrationOption struct {
 func (o FuncMigrationOption) apply(service *Service) {
 	service.funcMigrations[o.Migration.Filename()] = o.Migration
 }
+
+// FuncMigrationContextOption is like FuncMigrationOption, but for code based
+// migrations that need the context given to MigrateContext.
+type FuncMigrationContextOption struct {
+	// Migration is the FuncMigrationContext that will be applied by a call to
+	// its ApplyContext func.
+	Migration FuncMigrationContext
+}
+
+func (o FuncMigrationContextOption) apply(service *Service) {
+	service.funcMigrations[o.Migration.Filename()] = funcMigrationContext{o.Migration}
+}
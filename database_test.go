@@ -17,6 +17,7 @@ func TestService_WithFolder(t *testing.T) {
 		migrationLockTable: "migration_lock",
 		migrationFolder:    "test-name",
 		lockTimeoutMinutes: 15,
+		repeatablePrefix:   "R__",
 		fs:                 os.DirFS("."),
 		funcMigrations:     map[string]FuncMigration{},
 	}, s)
@@ -32,6 +33,7 @@ func TestService_WithLockTableName(t *testing.T) {
 		migrationLockTable: "test-name",
 		migrationFolder:    "db/migrations",
 		lockTimeoutMinutes: 15,
+		repeatablePrefix:   "R__",
 		fs:                 os.DirFS("."),
 		funcMigrations:     map[string]FuncMigration{},
 	}, s)
@@ -47,6 +49,7 @@ func TestService_WithLockTimeoutMinutes(t *testing.T) {
 		migrationLockTable: "migration_lock",
 		migrationFolder:    "db/migrations",
 		lockTimeoutMinutes: 20,
+		repeatablePrefix:   "R__",
 		fs:                 os.DirFS("."),
 		funcMigrations:     map[string]FuncMigration{},
 	}, s)
@@ -62,6 +65,23 @@ func TestService_WithTableName(t *testing.T) {
 		migrationLockTable: "migration_lock",
 		migrationFolder:    "db/migrations",
 		lockTimeoutMinutes: 15,
+		repeatablePrefix:   "R__",
+		fs:                 os.DirFS("."),
+		funcMigrations:     map[string]FuncMigration{},
+	}, s)
+}
+
+func TestService_WithRepeatablePrefix(t *testing.T) {
+	s := New(nil, Config{
+		RepeatablePrefix: "repeatable-",
+	})
+	assert.Equal(t, &Service{
+		logger:             s.logger,
+		migrationTable:     "migration",
+		migrationLockTable: "migration_lock",
+		migrationFolder:    "db/migrations",
+		lockTimeoutMinutes: 15,
+		repeatablePrefix:   "repeatable-",
 		fs:                 os.DirFS("."),
 		funcMigrations:     map[string]FuncMigration{},
 	}, s)
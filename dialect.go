@@ -0,0 +1,395 @@
+package migration
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect describes the SQL dialect of the database being migrated.
+type Dialect interface {
+	// Name returns the short name of the dialect, such as "postgres".
+	Name() string
+
+	// SplitStatements splits the contents of a SQL migration file into the statements to execute, in order.
+	SplitStatements(sql string) ([]Statement, error)
+
+	// QuoteIdentifier quotes the name of a table, column or schema, so that it can be used in SQL as it is.
+	QuoteIdentifier(name string) string
+
+	// Placeholder returns the bind parameter placeholder for the nth parameter of a statement, starting at 1.
+	Placeholder(n int) string
+
+	// TimestampType returns the column type of timestamps in the migration and lock tables.
+	TimestampType() string
+
+	// BooleanType returns the column type of booleans in the migration table.
+	BooleanType() string
+
+	// CreateTable returns a statement that creates a table with the given column definitions, unless it already
+	// exists. The table name is already quoted.
+	CreateTable(table string, columns []string) string
+
+	// TableExists returns a query, and its arguments, that returns the number of tables with the given name in the
+	// given schema. An empty schema is the current schema of the connection.
+	TableExists(schema, table string) (string, []any)
+
+	// OlderThan returns a condition that is true for rows where the timestamp in column is more than the given
+	// number of minutes before the current time of the database. It is used to remove stale locks.
+	OlderThan(column string, minutes int) string
+}
+
+// Statement is a statement in a SQL migration file.
+type Statement struct {
+	// SQL is the statement, as written in the file.
+	SQL string
+
+	// Offset is the byte offset of the statement in the file. It is used to tell where a failing statement is.
+	Offset int
+}
+
+// DialectOption sets the SQL dialect of the database. Without it, the dialect is detected from the driver of the
+// database, and GenericDialect is used for drivers that are not known. Set it for those drivers, which include known
+// drivers wrapped e.g. for instrumentation, as the ? placeholders of GenericDialect do not work with all databases.
+type DialectOption struct {
+	Dialect Dialect
+}
+
+func (o DialectOption) apply(service *Service) {
+	service.dialect = o.Dialect
+}
+
+// currentDialect returns the configured dialect, or the dialect detected from the driver if none is configured.
+func (s *Service) currentDialect() Dialect {
+	if s.dialect != nil {
+		return s.dialect
+	}
+
+	return detectDialect(s.db)
+}
+
+// quoteTable quotes a table name with the current dialect, qualified with its schema if it has one.
+func (s *Service) quoteTable(name string) string {
+	d := s.currentDialect()
+
+	if schema, table := s.splitTable(name); schema != "" {
+		return d.QuoteIdentifier(schema) + "." + d.QuoteIdentifier(table)
+	}
+
+	return d.QuoteIdentifier(name)
+}
+
+// param returns the placeholder of the nth bind parameter of a statement in the current dialect, starting at 1.
+func (s *Service) param(n int) string {
+	return s.currentDialect().Placeholder(n)
+}
+
+// tableExistsQuery returns the query of the current dialect that tells whether a table exists.
+func (s *Service) tableExistsQuery(name string) (string, []any) {
+	return s.currentDialect().TableExists(s.splitTable(name))
+}
+
+// quoteWith quotes name with the given quote characters, doubling any closing quote character in it.
+func quoteWith(name, open, closing string) string {
+	return open + strings.ReplaceAll(name, closing, closing+closing) + closing
+}
+
+// createTableIfNotExists returns a create table if not exists statement, as supported by most databases.
+func createTableIfNotExists(table string, columns []string) string {
+	return fmt.Sprintf("create table if not exists %s (\n\t%s\n)", table, strings.Join(columns, ",\n\t"))
+}
+
+// informationSchemaTableExists returns a query for information_schema.tables, as supported by most databases.
+// currentSchema is the SQL expression for the current schema, or empty if any schema will do.
+func informationSchemaTableExists(d Dialect, currentSchema, schema, table string) (string, []any) {
+	if schema != "" {
+		return fmt.Sprintf("select count(*) from information_schema.tables where table_schema = %s and table_name = %s",
+			d.Placeholder(1), d.Placeholder(2)), []any{schema, table}
+	}
+
+	if currentSchema == "" {
+		return fmt.Sprintf("select count(*) from information_schema.tables where table_name = %s",
+			d.Placeholder(1)), []any{table}
+	}
+
+	return fmt.Sprintf("select count(*) from information_schema.tables where table_schema = %s and table_name = %s",
+		currentSchema, d.Placeholder(1)), []any{table}
+}
+
+// GenericDialect is used for databases without a dialect of their own. Statements are split on semicolons outside of
+// quotes, comments, $$ quoted strings and the BEGIN ... END bodies of CREATE TRIGGER statements. DELIMITER lines
+// change the terminator, as in the mysql client.
+type GenericDialect struct{}
+
+// Name returns "generic".
+func (GenericDialect) Name() string {
+	return "generic"
+}
+
+// SplitStatements splits sql into statements.
+func (GenericDialect) SplitStatements(sql string) ([]Statement, error) {
+	return splitSQL(sql, splitRules{
+		dollarQuotes:       true,
+		triggerBlocks:      true,
+		delimiterDirective: true,
+	})
+}
+
+// OlderThan returns a condition with a standard SQL interval, which most databases support.
+func (GenericDialect) OlderThan(column string, minutes int) string {
+	return fmt.Sprintf("%s < current_timestamp - interval '%d' minute", column, minutes)
+}
+
+// QuoteIdentifier returns name as it is. Not all databases accept double quotes around names, and the names of the
+// migration and lock tables are plain identifiers, so they need no quotes unless they are reserved words.
+func (GenericDialect) QuoteIdentifier(name string) string {
+	return name
+}
+
+// Placeholder returns ?.
+func (GenericDialect) Placeholder(int) string {
+	return "?"
+}
+
+// TimestampType returns timestamp.
+func (GenericDialect) TimestampType() string {
+	return "timestamp"
+}
+
+// BooleanType returns boolean.
+func (GenericDialect) BooleanType() string {
+	return "boolean"
+}
+
+// CreateTable returns a create table if not exists statement.
+func (GenericDialect) CreateTable(table string, columns []string) string {
+	return createTableIfNotExists(table, columns)
+}
+
+// TableExists returns a query for information_schema.tables, in any schema unless one is given.
+func (d GenericDialect) TableExists(schema, table string) (string, []any) {
+	return informationSchemaTableExists(d, "", schema, table)
+}
+
+// PostgresDialect is the dialect of PostgreSQL. Statements are split on semicolons outside of quotes, E'...' strings,
+// $$ quoted strings, nested comments and BEGIN ATOMIC ... END blocks.
+type PostgresDialect struct{}
+
+// Name returns "postgres".
+func (PostgresDialect) Name() string {
+	return "postgres"
+}
+
+// SplitStatements splits sql into statements.
+func (PostgresDialect) SplitStatements(sql string) ([]Statement, error) {
+	return splitSQL(sql, splitRules{
+		escapeStrings:  true,
+		dollarQuotes:   true,
+		nestedComments: true,
+		atomicBlocks:   true,
+	})
+}
+
+// OlderThan returns a condition with an interval.
+func (PostgresDialect) OlderThan(column string, minutes int) string {
+	return fmt.Sprintf("%s < current_timestamp - interval '%d minutes'", column, minutes)
+}
+
+// QuoteIdentifier quotes name with double quotes. Quoted names are case-sensitive.
+func (PostgresDialect) QuoteIdentifier(name string) string {
+	return quoteWith(name, `"`, `"`)
+}
+
+// Placeholder returns $n.
+func (PostgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// TimestampType returns timestamp.
+func (PostgresDialect) TimestampType() string {
+	return "timestamp"
+}
+
+// BooleanType returns boolean.
+func (PostgresDialect) BooleanType() string {
+	return "boolean"
+}
+
+// CreateTable returns a create table if not exists statement.
+func (PostgresDialect) CreateTable(table string, columns []string) string {
+	return createTableIfNotExists(table, columns)
+}
+
+// TableExists returns a query for information_schema.tables, in the current schema unless one is given.
+func (d PostgresDialect) TableExists(schema, table string) (string, []any) {
+	return informationSchemaTableExists(d, "current_schema()", schema, table)
+}
+
+// MySQLDialect is the dialect of MySQL and MariaDB. Statements are split on semicolons outside of quotes, backslash
+// escapes, `identifiers` and comments, including # comments. As in the mysql client, DELIMITER lines change the
+// terminator, which allows stored procedures and triggers to be written with BEGIN ... END bodies.
+type MySQLDialect struct{}
+
+// Name returns "mysql".
+func (MySQLDialect) Name() string {
+	return "mysql"
+}
+
+// SplitStatements splits sql into statements.
+func (MySQLDialect) SplitStatements(sql string) ([]Statement, error) {
+	return splitSQL(sql, splitRules{
+		backslashEscapes:      true,
+		backticks:             true,
+		hashComments:          true,
+		dashCommentNeedsSpace: true,
+		executableComments:    true,
+		delimiterDirective:    true,
+	})
+}
+
+// OlderThan returns a condition with timestampadd.
+func (MySQLDialect) OlderThan(column string, minutes int) string {
+	return fmt.Sprintf("%s < timestampadd(minute, %d, current_timestamp)", column, -minutes)
+}
+
+// QuoteIdentifier quotes name with backticks.
+func (MySQLDialect) QuoteIdentifier(name string) string {
+	return quoteWith(name, "`", "`")
+}
+
+// Placeholder returns ?.
+func (MySQLDialect) Placeholder(int) string {
+	return "?"
+}
+
+// TimestampType returns datetime, which unlike timestamp has no implicit default or automatic update.
+func (MySQLDialect) TimestampType() string {
+	return "datetime"
+}
+
+// BooleanType returns boolean.
+func (MySQLDialect) BooleanType() string {
+	return "boolean"
+}
+
+// CreateTable returns a create table if not exists statement.
+func (MySQLDialect) CreateTable(table string, columns []string) string {
+	return createTableIfNotExists(table, columns)
+}
+
+// TableExists returns a query for information_schema.tables, in the current database unless one is given.
+func (d MySQLDialect) TableExists(schema, table string) (string, []any) {
+	return informationSchemaTableExists(d, "database()", schema, table)
+}
+
+// SQLiteDialect is the dialect of SQLite. Statements are split on semicolons outside of quotes, `identifiers`,
+// [identifiers], comments and the BEGIN ... END bodies of CREATE TRIGGER statements.
+type SQLiteDialect struct{}
+
+// Name returns "sqlite".
+func (SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+// SplitStatements splits sql into statements.
+func (SQLiteDialect) SplitStatements(sql string) ([]Statement, error) {
+	return splitSQL(sql, splitRules{
+		backticks:     true,
+		brackets:      true,
+		triggerBlocks: true,
+	})
+}
+
+// OlderThan returns a condition with datetime, which formats the time like current_timestamp does.
+func (SQLiteDialect) OlderThan(column string, minutes int) string {
+	return fmt.Sprintf("%s < datetime('now', '-%d minutes')", column, minutes)
+}
+
+// QuoteIdentifier quotes name with double quotes.
+func (SQLiteDialect) QuoteIdentifier(name string) string {
+	return quoteWith(name, `"`, `"`)
+}
+
+// Placeholder returns ?.
+func (SQLiteDialect) Placeholder(int) string {
+	return "?"
+}
+
+// TimestampType returns timestamp, which drivers read as a time.
+func (SQLiteDialect) TimestampType() string {
+	return "timestamp"
+}
+
+// BooleanType returns boolean.
+func (SQLiteDialect) BooleanType() string {
+	return "boolean"
+}
+
+// CreateTable returns a create table if not exists statement.
+func (SQLiteDialect) CreateTable(table string, columns []string) string {
+	return createTableIfNotExists(table, columns)
+}
+
+// TableExists returns a query for sqlite_master, of the main database unless an attached one is given.
+func (d SQLiteDialect) TableExists(schema, table string) (string, []any) {
+	if schema != "" {
+		return fmt.Sprintf("select count(*) from %s.sqlite_master where type = 'table' and name = ?",
+			d.QuoteIdentifier(schema)), []any{table}
+	}
+
+	return "select count(*) from sqlite_master where type = 'table' and name = ?", []any{table}
+}
+
+// SQLServerDialect is the dialect of Microsoft SQL Server. Files are split into batches on GO lines, as in sqlcmd,
+// and each batch is sent to the database as it is, semicolons included. A file without GO lines is a single batch.
+type SQLServerDialect struct{}
+
+// Name returns "sqlserver".
+func (SQLServerDialect) Name() string {
+	return "sqlserver"
+}
+
+// SplitStatements splits sql into batches.
+func (SQLServerDialect) SplitStatements(sql string) ([]Statement, error) {
+	return splitSQL(sql, splitRules{
+		brackets:       true,
+		nestedComments: true,
+		goBatches:      true,
+	})
+}
+
+// OlderThan returns a condition with dateadd.
+func (SQLServerDialect) OlderThan(column string, minutes int) string {
+	return fmt.Sprintf("%s < dateadd(minute, %d, current_timestamp)", column, -minutes)
+}
+
+// QuoteIdentifier quotes name with brackets.
+func (SQLServerDialect) QuoteIdentifier(name string) string {
+	return quoteWith(name, "[", "]")
+}
+
+// Placeholder returns @pn.
+func (SQLServerDialect) Placeholder(n int) string {
+	return fmt.Sprintf("@p%d", n)
+}
+
+// TimestampType returns datetime2.
+func (SQLServerDialect) TimestampType() string {
+	return "datetime2"
+}
+
+// BooleanType returns bit.
+func (SQLServerDialect) BooleanType() string {
+	return "bit"
+}
+
+// CreateTable returns a statement that creates the table if object_id does not find it, as SQL Server does not
+// support create table if not exists.
+func (SQLServerDialect) CreateTable(table string, columns []string) string {
+	return fmt.Sprintf("if object_id(N'%s', N'U') is null create table %s (\n\t%s\n)",
+		strings.ReplaceAll(table, "'", "''"), table, strings.Join(columns, ",\n\t"))
+}
+
+// TableExists returns a query for information_schema.tables, in the default schema unless one is given.
+func (d SQLServerDialect) TableExists(schema, table string) (string, []any) {
+	return informationSchemaTableExists(d, "schema_name()", schema, table)
+}
@@ -0,0 +1,171 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialect(t *testing.T) {
+	tests := []struct {
+		dialect       Dialect
+		quoted        string
+		placeholder   string
+		timestampType string
+		booleanType   string
+		createTable   string
+		tableExists   string
+	}{
+		{
+			dialect: GenericDialect{}, quoted: `my"table`, placeholder: "?", timestampType: "timestamp",
+			booleanType: "boolean",
+			createTable: "create table if not exists t (\n\tid integer\n)",
+			tableExists: "select count(*) from information_schema.tables where table_name = ?",
+		},
+		{
+			dialect: PostgresDialect{}, quoted: `"my""table"`, placeholder: "$2", timestampType: "timestamp",
+			booleanType: "boolean",
+			createTable: "create table if not exists t (\n\tid integer\n)",
+			tableExists: "select count(*) from information_schema.tables where table_schema = current_schema() and " +
+				"table_name = $1",
+		},
+		{
+			dialect: MySQLDialect{}, quoted: "`my\"table`", placeholder: "?", timestampType: "datetime",
+			booleanType: "boolean",
+			createTable: "create table if not exists t (\n\tid integer\n)",
+			tableExists: "select count(*) from information_schema.tables where table_schema = database() and " +
+				"table_name = ?",
+		},
+		{
+			dialect: SQLiteDialect{}, quoted: `"my""table"`, placeholder: "?", timestampType: "timestamp",
+			booleanType: "boolean",
+			createTable: "create table if not exists t (\n\tid integer\n)",
+			tableExists: "select count(*) from sqlite_master where type = 'table' and name = ?",
+		},
+		{
+			dialect: SQLServerDialect{}, quoted: `[my"table]`, placeholder: "@p2", timestampType: "datetime2",
+			booleanType: "bit",
+			createTable: "if object_id(N't', N'U') is null create table t (\n\tid integer\n)",
+			tableExists: "select count(*) from information_schema.tables where table_schema = schema_name() and " +
+				"table_name = @p1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			assert.Equal(t, tt.quoted, tt.dialect.QuoteIdentifier(`my"table`))
+			assert.Equal(t, tt.placeholder, tt.dialect.Placeholder(2))
+			assert.Equal(t, tt.timestampType, tt.dialect.TimestampType())
+			assert.Equal(t, tt.booleanType, tt.dialect.BooleanType())
+			assert.Equal(t, tt.createTable, tt.dialect.CreateTable("t", []string{"id integer"}))
+
+			query, args := tt.dialect.TableExists("", "migration")
+			assert.Equal(t, tt.tableExists, query)
+			assert.Equal(t, []any{"migration"}, args)
+		})
+	}
+}
+
+func TestService_quoteTable(t *testing.T) {
+	s := New(nil, DialectOption{Dialect: MySQLDialect{}})
+
+	assert.Equal(t, "`migration`", s.quoteTable("migration"))
+	assert.Equal(t, "`ops`.`migration`", s.quoteTable("ops.migration"))
+}
+
+func TestService_Migrate_QuotedTableNames(t *testing.T) {
+	db := openTestDB(t)
+	s := newTestService(t, db, fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table users (id int);")},
+	}, Config{TableName: "order", LockTableName: "group"})
+
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	for _, table := range []string{"order", "group"} {
+		exists, err := s.tableExists(context.Background(), table)
+		assert.NoError(t, err)
+		assert.True(t, exists, table)
+	}
+
+	exists, err := s.tableExists(context.Background(), "migration")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	count := 0
+	assert.NoError(t, db.QueryRow(`select count(*) from "order"`).Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+// wrappedDriver wraps the SQLite driver, as instrumentation like otelsql does, so that the dialect is not
+// detected. It records the statements prepared on its connections.
+type wrappedDriver struct {
+	dsn string
+
+	mu         sync.Mutex
+	statements []string
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := (&sqlite3.SQLiteDriver{}).Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrappedConn{Conn: conn, d: d}, nil
+}
+
+func (d *wrappedDriver) Connect(context.Context) (driver.Conn, error) {
+	return d.Open(d.dsn)
+}
+
+func (d *wrappedDriver) Driver() driver.Driver {
+	return d
+}
+
+// wrappedConn only has the methods of driver.Conn, so that all statements are prepared.
+type wrappedConn struct {
+	driver.Conn
+	d *wrappedDriver
+}
+
+func (c wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	c.d.statements = append(c.d.statements, query)
+	c.d.mu.Unlock()
+
+	return c.Conn.Prepare(query)
+}
+
+func TestService_Migrate_WrappedDriver(t *testing.T) {
+	d := &wrappedDriver{dsn: filepath.Join(t.TempDir(), "mig.db")}
+	db := sql.OpenDB(d)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	assert.Equal(t, GenericDialect{}, detectDialect(db))
+
+	s := newTestService(t, db, fstest.MapFS{"migrations/1.sql": {Data: []byte("create table users (id int);")}})
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	assert.Contains(t, d.statements, "create table if not exists migration (\n\tid varchar(255) primary key,\n\t"+
+		"date timestamp default current_timestamp,\n\tchecksum varchar(255),\n\tvariant varchar(255),\n\t"+
+		"baselined boolean\n)")
+
+	for _, stmt := range d.statements {
+		assert.NotContains(t, stmt, `"migration`, "the table names are not quoted")
+	}
+}
@@ -0,0 +1,33 @@
+package migration
+
+import (
+	"strings"
+)
+
+// noTransactionDirective makes the statements of a SQL migration run without a transaction. It is needed for
+// statements that cannot run in a transaction, such as CREATE INDEX CONCURRENTLY in PostgreSQL.
+const noTransactionDirective = "migration:no-transaction"
+
+// hasDirective reports whether the header of a SQL migration has the directive. The header is the -- comments and
+// empty lines at the start of the file, and a directive is a comment holding only the directive, like
+//
+//	-- migration:no-transaction
+func hasDirective(src, directive string) bool {
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		if !strings.HasPrefix(line, "--") {
+			return false
+		}
+
+		if strings.TrimSpace(strings.TrimPrefix(line, "--")) == directive {
+			return true
+		}
+	}
+
+	return false
+}
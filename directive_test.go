@@ -0,0 +1,82 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_hasDirective(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want bool
+	}{
+		{name: "first line", src: "-- migration:no-transaction\nvacuum;", want: true},
+		{name: "without space", src: "--migration:no-transaction\nvacuum;", want: true},
+		{name: "after other comments", src: "\n-- Reclaim space.\n\n  --  migration:no-transaction  \nvacuum;", want: true},
+		{name: "windows line endings", src: "-- migration:no-transaction\r\nvacuum;", want: true},
+		{name: "missing", src: "-- Reclaim space.\nvacuum;", want: false},
+		{name: "after first statement", src: "vacuum;\n-- migration:no-transaction\n", want: false},
+		{name: "in block comment", src: "/* migration:no-transaction */\nvacuum;", want: false},
+		{name: "with more text", src: "-- migration:no-transaction please\nvacuum;", want: false},
+		{name: "empty file", src: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, hasDirective(tt.src, noTransactionDirective))
+		})
+	}
+}
+
+func TestService_Migrate_NoTransaction(t *testing.T) {
+	t.Run("Statement that cannot run in a transaction", func(t *testing.T) {
+		db := openTestDB(t)
+
+		// SQLite cannot VACUUM inside a transaction.
+		inTx := fstest.MapFS{"migrations/1.sql": {Data: []byte("create table a (id int);\nvacuum;")}}
+		err := newTestService(t, db, inTx).Migrate()
+		assert.Error(t, err)
+
+		noTx := fstest.MapFS{"migrations/1.sql": {Data: []byte(
+			"-- migration:no-transaction\ncreate table a (id int);\nvacuum;")}}
+		s := newTestService(t, db, noTx)
+
+		plan, err := s.Plan()
+		if assert.NoError(t, err) && assert.Len(t, plan, 1) {
+			assert.True(t, plan[0].NoTransaction)
+		}
+
+		assert.NoError(t, s.Migrate())
+		assert.NoError(t, s.Validate())
+	})
+
+	t.Run("Failing statement is reported as not rolled back", func(t *testing.T) {
+		db := openTestDB(t)
+
+		noTx := fstest.MapFS{"migrations/1.sql": {Data: []byte(
+			"-- migration:no-transaction\ncreate table a (id int);\ncreate table b (id int);\nnot sql;")}}
+		s := newTestService(t, db, noTx)
+
+		err := s.Migrate()
+
+		var stmtErr *StatementError
+		if assert.ErrorAs(t, err, &stmtErr) {
+			assert.True(t, stmtErr.NoTransaction)
+			assert.Equal(t, 3, stmtErr.Index)
+			assert.Contains(t, err.Error(), "1.sql:4:1: failing statement 3 [not sql;] in migration without transaction, "+
+				"the 2 statements before it are not rolled back")
+		}
+
+		// The statements before the failing one remain, but the migration is not recorded.
+		tables, err := getTableNames(s, Sqlite)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "migration", "migration_lock"}, tables)
+
+		count := 0
+		assert.NoError(t, db.QueryRow("select count(*) from migration").Scan(&count))
+		assert.Equal(t, 0, count)
+	})
+}
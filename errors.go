@@ -0,0 +1,153 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrLockNotAcquired is returned when the migration lock could not be taken, because another instance is migrating
+// the database.
+var ErrLockNotAcquired = errors.New("migration already in progress. failed to get lock")
+
+// ErrMigrationNotFound is returned when a migration given by ID is not in the migration folder.
+var ErrMigrationNotFound = errors.New("migration not found")
+
+// ErrInvalidName is returned when the name of the migration table, the lock table or their schema is not a plain
+// identifier.
+var ErrInvalidName = errors.New("invalid table or schema name")
+
+// ErrLowerCaseTable is returned on PostgreSQL when the migration or lock table has upper-case letters in its name, but
+// only exists with a lower-case name, as created by versions that did not quote the names.
+var ErrLowerCaseTable = errors.New("table only exists with a lower-case name")
+
+// ErrNoLockTable is returned by LockStatus and ForceUnlock when a Locker is used instead of the lock table.
+var ErrNoLockTable = errors.New("the lock table is not used when a Locker is set")
+
+// ChecksumMismatchError is returned when an applied migration has changed since it was applied.
+type ChecksumMismatchError struct {
+	// ID is the ID of the migration.
+	ID string
+
+	// Expected is the checksum stored in the migration table when the migration was applied.
+	Expected string
+
+	// Actual is the checksum of the migration in the migration folder.
+	Actual string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("file %s has been updated since it was migrated, wanted checksum '%s', got '%s'",
+		e.ID, e.Expected, e.Actual)
+}
+
+// StatementError is returned when a statement in a SQL migration fails.
+type StatementError struct {
+	// ID is the ID of the migration.
+	ID string
+
+	// File is the name of the file that was applied, which differs from ID for dialect variants.
+	File string
+
+	// Index is the position of the failing statement in the migration, starting at 1.
+	Index int
+
+	// SQL is the failing statement.
+	SQL string
+
+	// Err is the error returned by the database.
+	Err error
+
+	// NoTransaction is set if the migration has the no-transaction directive. The statements before the failing
+	// one have then been applied, and are not rolled back.
+	NoTransaction bool
+
+	// Rendered is set if the migration is a template. Line, Column and Excerpt then point into the rendered SQL,
+	// which can have other lines than the template.
+	Rendered bool
+
+	// Line and Column tell where in the file the error is, starting at 1. They point at the position reported by
+	// the database if there is one, such as the Position of a PostgreSQL error, and otherwise at the start of the
+	// failing statement.
+	Line, Column int
+
+	// Excerpt is the line of the file that Line points at, shortened if it is long.
+	Excerpt string
+}
+
+func (e *StatementError) Error() string {
+	file := e.File
+	if file == "" {
+		file = e.ID
+	}
+
+	if e.Rendered {
+		file += " (rendered)"
+	}
+
+	msg := fmt.Sprintf("%s:%d:%d: failing statement %d [%s]", file, e.Line, e.Column, e.Index, e.Excerpt)
+
+	if e.NoTransaction {
+		return fmt.Sprintf("%s in migration without transaction, the %d statements before it are not rolled back: %v",
+			msg, e.Index-1, e.Err)
+	}
+
+	return fmt.Sprintf("%s: %v", msg, e.Err)
+}
+
+// Unwrap returns the error returned by the database.
+func (e *StatementError) Unwrap() error {
+	return e.Err
+}
+
+// FuncMigrationError is returned when the Apply func of a FuncMigration fails.
+type FuncMigrationError struct {
+	// ID is the ID of the migration.
+	ID string
+
+	// Err is the error returned by the FuncMigration.
+	Err error
+}
+
+func (e *FuncMigrationError) Error() string {
+	return fmt.Sprintf("failed to apply migration: %v", e.Err)
+}
+
+// Unwrap returns the error returned by the FuncMigration.
+func (e *FuncMigrationError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned by Validate when the database does not match the migration folder.
+type ValidationError struct {
+	// Mismatches are the applied migrations that have changed since they were applied.
+	Mismatches []*ChecksumMismatchError
+
+	// Pending are the IDs of the migrations that have not been applied yet.
+	Pending []string
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Mismatches)+1)
+
+	for _, m := range e.Mismatches {
+		msgs = append(msgs, m.Error())
+	}
+
+	if len(e.Pending) > 0 {
+		msgs = append(msgs, fmt.Sprintf("pending migrations: %s", strings.Join(e.Pending, ", ")))
+	}
+
+	return fmt.Sprintf("validation failed: %s", strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the checksum mismatches, so that they can be found with errors.As.
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Mismatches))
+
+	for _, m := range e.Mismatches {
+		errs = append(errs, m)
+	}
+
+	return errs
+}
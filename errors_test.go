@@ -0,0 +1,70 @@
+package migration
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	code_based_fail "github.com/stimtech/go-migration/v2/test/code-based-fail"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestService_Migrate_ChecksumMismatchError(t *testing.T) {
+	db := openTestDB(t)
+
+	err := newTestService(t, db, fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table first (id int);")},
+	}).Migrate()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	err = newTestService(t, db, fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table first (id int, name text);")},
+	}).Migrate()
+
+	var mismatch *ChecksumMismatchError
+	if assert.ErrorAs(t, err, &mismatch) {
+		assert.Equal(t, &ChecksumMismatchError{
+			ID:       "1.sql",
+			Expected: "de17e395bdb9e15558de7dbbd3b87df2",
+			Actual:   "3fe26d175aad4d2b44e108b89b862416",
+		}, mismatch)
+	}
+}
+
+func TestService_Migrate_StatementError(t *testing.T) {
+	db := openTestDB(t)
+
+	err := newTestService(t, db, fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table first (id int);\nnot sql;")},
+	}).Migrate()
+
+	var stmtErr *StatementError
+	if assert.ErrorAs(t, err, &stmtErr) {
+		assert.Equal(t, "1.sql", stmtErr.ID)
+		assert.Equal(t, 2, stmtErr.Index)
+		assert.Equal(t, "\nnot sql", stmtErr.SQL)
+		assert.Error(t, stmtErr.Err)
+		assert.Equal(t, 2, stmtErr.Line)
+		assert.Equal(t, 1, stmtErr.Column)
+		assert.Contains(t, err.Error(), "failed to apply migration 1.sql: 1.sql:2:1: failing statement 2 [not sql;]: ")
+	}
+}
+
+func TestService_Migrate_FuncMigrationError(t *testing.T) {
+	db := openTestDB(t)
+
+	err := New(db, ZapOption{Logger: zap.NewNop()}, Config{MigrationFolder: "test/code-based-fail"},
+		FuncMigrationOption{Migration: &code_based_fail.CBFailTest2{Name: "cb_fail_test2.go"}}).Migrate()
+
+	var fmErr *FuncMigrationError
+	if assert.ErrorAs(t, err, &fmErr) {
+		assert.Equal(t, "cb_fail_test2.go", fmErr.ID)
+		assert.Error(t, fmErr.Err)
+	}
+
+	assert.False(t, errors.Is(err, ErrLockNotAcquired))
+}
@@ -1,6 +1,9 @@
 package migration
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+)
 
 // FuncMigration can be implemented by apps relying on go-migration to allow for
 // code based migrations. If a call to Apply returns with a non-nil error, the
@@ -14,3 +17,27 @@ type FuncMigration interface {
 	// migration is implemented.
 	Filename() string
 }
+
+// FuncMigrationContext is the context aware variant of FuncMigration. The
+// context passed to ApplyContext is the one given to MigrateContext, and is
+// cancelled if the migration is aborted.
+type FuncMigrationContext interface {
+	// ApplyContext should perform the migration. Implementations should not
+	// commit nor rollback the transaction.
+	ApplyContext(ctx context.Context, tx *sql.Tx) error
+
+	// Filename should declare the file in the migrations dir in which the
+	// migration is implemented.
+	Filename() string
+}
+
+// funcMigrationContext adapts a FuncMigrationContext to a FuncMigration, so
+// that both kinds can be kept in the same registry.
+type funcMigrationContext struct {
+	FuncMigrationContext
+}
+
+// Apply applies the migration without a cancellable context.
+func (m funcMigrationContext) Apply(tx *sql.Tx) error {
+	return m.ApplyContext(context.Background(), tx)
+}
@@ -0,0 +1,383 @@
+package migration
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Locker makes sure that only one instance of go-migration migrates the database at the same time.
+type Locker interface {
+	// TryLock tries to take the lock once, without waiting for it. It returns false if another instance holds the
+	// lock, and an error if it could not be determined whether the lock is held.
+	TryLock(ctx context.Context) (bool, error)
+
+	// Unlock releases the lock taken by TryLock.
+	Unlock(ctx context.Context) error
+}
+
+// LockerOption sets the Locker used to make sure only one instance migrates the database at the same time. Without
+// it, a lock table is used, see Config.LockTableName.
+type LockerOption struct {
+	Locker Locker
+}
+
+func (o LockerOption) apply(service *Service) {
+	service.locker = o.Locker
+}
+
+// currentLocker returns the configured locker, or the lock table if none is configured.
+func (s *Service) currentLocker() Locker {
+	if s.locker != nil {
+		return s.locker
+	}
+
+	return &tableLocker{s: s}
+}
+
+// tableLocker takes the lock by inserting a row into the lock table, which fails if the row is already there. It works
+// on most databases, but a lock left behind by an instance that died is only removed once its heartbeat is older than
+// the lock timeout.
+//
+// The row records the owner of the lock, a token unique to each lock taken, together with the hostname and process
+// ID of the instance. While the lock is held, the heartbeat is renewed in the background, so that a long migration
+// does not have its lock removed as stale. The lock is only released by its owner.
+type tableLocker struct {
+	s     *Service
+	owner string
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// TryLock inserts the lock row, after removing it if it is stale, and starts renewing its heartbeat.
+func (l *tableLocker) TryLock(ctx context.Context) (bool, error) {
+	s := l.s
+
+	// The age of the lock is determined by the database, so that the clocks of the instances do not matter.
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf("delete from %s where %s", s.quoteTable(s.migrationLockTable),
+		s.currentDialect().OlderThan("coalesce(heartbeat_at, created_at)", s.lockTimeoutMinutes))); err != nil {
+		s.logger.Warn(fmt.Sprintf("failed to remove stale migration lock: %v", err))
+	}
+
+	owner, err := newOwnerToken()
+	if err != nil {
+		return false, err
+	}
+
+	hostname, _ := os.Hostname()
+
+	if _, err := s.db.ExecContext(ctx, fmt.Sprintf(
+		"insert into %s(id, owner, hostname, pid, heartbeat_at) values(1, %s, %s, %s, current_timestamp)",
+		s.quoteTable(s.migrationLockTable), s.param(1), s.param(2), s.param(3)), owner, hostname, os.Getpid()); err != nil {
+		// The insert fails while another instance holds the lock, but it may also fail for other reasons, such as
+		// placeholders of the wrong dialect.
+		held, heldErr := l.held(ctx)
+		if heldErr != nil || !held {
+			return false, fmt.Errorf("failed to insert migration lock: %w", err)
+		}
+
+		return false, nil
+	}
+
+	l.owner = owner
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+
+	go l.heartbeat(owner, l.stop, l.done)
+
+	return true, nil
+}
+
+// held reports whether the lock row exists.
+func (l *tableLocker) held(ctx context.Context) (bool, error) {
+	count := 0
+
+	if err := l.s.db.QueryRowContext(ctx, fmt.Sprintf("select count(*) from %s where id = 1",
+		l.s.quoteTable(l.s.migrationLockTable))).Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// heartbeat renews the heartbeat of the lock row of owner until stop is closed.
+func (l *tableLocker) heartbeat(owner string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	s := l.s
+	ticker := time.NewTicker(l.heartbeatInterval())
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		res, err := s.db.ExecContext(context.Background(), fmt.Sprintf(
+			"update %s set heartbeat_at = current_timestamp where id = 1 and owner = %s",
+			s.quoteTable(s.migrationLockTable), s.param(1)), owner)
+		if err != nil {
+			s.logger.Warn(fmt.Sprintf("failed to renew migration lock: %v", err))
+
+			continue
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			s.logger.Warn("migration lock is no longer held, it has been removed by another instance")
+		}
+	}
+}
+
+// heartbeatInterval returns how often the heartbeat is renewed, a third of the lock timeout unless set by the lock
+// policy.
+func (l *tableLocker) heartbeatInterval() time.Duration {
+	if l.s.lockPolicy.Heartbeat > 0 {
+		return l.s.lockPolicy.Heartbeat
+	}
+
+	return time.Duration(l.s.lockTimeoutMinutes) * time.Minute / 3
+}
+
+// Unlock stops renewing the heartbeat, and deletes the lock row if it is still owned by this locker.
+func (l *tableLocker) Unlock(ctx context.Context) error {
+	if l.owner == "" {
+		return nil
+	}
+
+	close(l.stop)
+	<-l.done
+
+	owner := l.owner
+	l.owner = ""
+
+	_, err := l.s.db.ExecContext(ctx, fmt.Sprintf("delete from %s where id = 1 and owner = %s",
+		l.s.quoteTable(l.s.migrationLockTable), l.s.param(1)), owner)
+
+	return err
+}
+
+// newOwnerToken returns a random token identifying the owner of a lock.
+func newOwnerToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create lock owner token: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// sessionLocker holds a lock that belongs to a database session, on a connection dedicated to it. The database
+// releases such a lock by itself when the session ends, so a lock held by an instance that died is not left behind.
+type sessionLocker struct {
+	db   *sql.DB
+	conn *sql.Conn
+}
+
+// tryLock takes the lock on a dedicated connection with lock, which returns false if another session holds it. The
+// connection is only kept while the lock is held.
+func (l *sessionLocker) tryLock(ctx context.Context, lock func(conn *sql.Conn) (bool, error)) (bool, error) {
+	if l.conn != nil {
+		return false, errors.New("lock is already held")
+	}
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	locked, err := lock(conn)
+	if err != nil || !locked {
+		_ = conn.Close()
+
+		return false, err
+	}
+
+	l.conn = conn
+
+	return true, nil
+}
+
+// unlock releases the lock with unlock, and returns the dedicated connection to the pool. If the lock could not be
+// released, the connection is closed instead, which ends the session and thereby releases the lock.
+func (l *sessionLocker) unlock(ctx context.Context, unlock func(conn *sql.Conn) error) error {
+	if l.conn == nil {
+		return nil
+	}
+
+	conn := l.conn
+	l.conn = nil
+
+	if err := unlock(conn); err != nil {
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		_ = conn.Close()
+
+		return err
+	}
+
+	return conn.Close()
+}
+
+// PostgresLocker takes a session level advisory lock with pg_try_advisory_lock.
+type PostgresLocker struct {
+	sessionLocker
+	key int64
+}
+
+// NewPostgresLocker returns a PostgresLocker for the advisory lock with the given key. All instances migrating the
+// same database must use the same key, and it must not be used for other purposes.
+func NewPostgresLocker(db *sql.DB, key int64) *PostgresLocker {
+	return &PostgresLocker{sessionLocker: sessionLocker{db: db}, key: key}
+}
+
+// TryLock takes the advisory lock, unless another session holds it.
+func (l *PostgresLocker) TryLock(ctx context.Context) (bool, error) {
+	return l.tryLock(ctx, func(conn *sql.Conn) (bool, error) {
+		var locked bool
+		err := conn.QueryRowContext(ctx, "select pg_try_advisory_lock($1)", l.key).Scan(&locked)
+
+		return locked, err
+	})
+}
+
+// Unlock releases the advisory lock.
+func (l *PostgresLocker) Unlock(ctx context.Context) error {
+	return l.unlock(ctx, func(conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "select pg_advisory_unlock($1)", l.key)
+
+		return err
+	})
+}
+
+// MySQLLocker takes a named lock with GET_LOCK, and releases it with RELEASE_LOCK.
+type MySQLLocker struct {
+	sessionLocker
+	name string
+}
+
+// NewMySQLLocker returns a MySQLLocker for the lock with the given name. Lock names are global to the server, so the
+// name should include the name of the database being migrated.
+func NewMySQLLocker(db *sql.DB, name string) *MySQLLocker {
+	return &MySQLLocker{sessionLocker: sessionLocker{db: db}, name: name}
+}
+
+// TryLock takes the named lock, unless another session holds it.
+func (l *MySQLLocker) TryLock(ctx context.Context) (bool, error) {
+	return l.tryLock(ctx, func(conn *sql.Conn) (bool, error) {
+		// GET_LOCK returns 1 if the lock was taken, 0 if another session holds it and NULL on errors.
+		var locked sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "select get_lock(?, 0)", l.name).Scan(&locked); err != nil {
+			return false, err
+		}
+
+		if !locked.Valid {
+			return false, fmt.Errorf("failed to get lock %s", l.name)
+		}
+
+		return locked.Int64 == 1, nil
+	})
+}
+
+// Unlock releases the named lock.
+func (l *MySQLLocker) Unlock(ctx context.Context) error {
+	return l.unlock(ctx, func(conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "select release_lock(?)", l.name)
+
+		return err
+	})
+}
+
+// SQLiteLocker takes the write lock of a SQLite database with BEGIN IMMEDIATE, and holds it until Unlock. The lock is
+// held on a file, so it is released by the operating system if the process dies.
+//
+// While the lock is held, no other connection can write to the database it is taken on. It must therefore be taken
+// on a database that is only used for locking, such as a file next to the database being migrated, and never on the
+// database being migrated.
+type SQLiteLocker struct {
+	sessionLocker
+}
+
+// NewSQLiteLocker returns a SQLiteLocker that takes the lock on lockDB, which must be a database that is only used
+// for locking.
+func NewSQLiteLocker(lockDB *sql.DB) *SQLiteLocker {
+	return &SQLiteLocker{sessionLocker: sessionLocker{db: lockDB}}
+}
+
+// TryLock begins an immediate transaction, unless another connection holds the write lock.
+func (l *SQLiteLocker) TryLock(ctx context.Context) (bool, error) {
+	return l.tryLock(ctx, func(conn *sql.Conn) (bool, error) {
+		// Fail at once instead of waiting for the busy timeout of the driver.
+		if _, err := conn.ExecContext(ctx, "pragma busy_timeout = 0"); err != nil {
+			return false, err
+		}
+
+		if _, err := conn.ExecContext(ctx, "begin immediate"); err != nil {
+			if strings.Contains(err.Error(), "database is locked") {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		return true, nil
+	})
+}
+
+// Unlock ends the immediate transaction.
+func (l *SQLiteLocker) Unlock(ctx context.Context) error {
+	return l.unlock(ctx, func(conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "rollback")
+
+		return err
+	})
+}
+
+// SQLServerLocker takes a session level application lock with sp_getapplock.
+type SQLServerLocker struct {
+	sessionLocker
+	resource string
+}
+
+// NewSQLServerLocker returns a SQLServerLocker for the application lock on the given resource, which is at most 255
+// characters long. Application locks are local to the database, so all instances migrating the same database must use
+// the same resource.
+func NewSQLServerLocker(db *sql.DB, resource string) *SQLServerLocker {
+	return &SQLServerLocker{sessionLocker: sessionLocker{db: db}, resource: resource}
+}
+
+// TryLock takes the application lock, unless another session holds it.
+func (l *SQLServerLocker) TryLock(ctx context.Context) (bool, error) {
+	return l.tryLock(ctx, func(conn *sql.Conn) (bool, error) {
+		// sp_getapplock returns 0 or 1 if the lock was taken, -1 if another session holds it and less on errors.
+		var result int
+		if err := conn.QueryRowContext(ctx, `declare @result int;
+exec @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = 0;
+select @result`, l.resource).Scan(&result); err != nil {
+			return false, err
+		}
+
+		if result < -1 {
+			return false, fmt.Errorf("failed to get application lock %s: sp_getapplock returned %d", l.resource, result)
+		}
+
+		return result >= 0, nil
+	})
+}
+
+// Unlock releases the application lock.
+func (l *SQLServerLocker) Unlock(ctx context.Context) error {
+	return l.unlock(ctx, func(conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "exec sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'", l.resource)
+
+		return err
+	})
+}
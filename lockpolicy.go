@@ -0,0 +1,87 @@
+package migration
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// LockPolicyOption sets how long and how often go-migration tries to take the migration lock while another instance
+// holds it. Without it, the lock is tried every 5 seconds for a minute.
+type LockPolicyOption struct {
+	// MaxWait is how long to wait for the lock in total, before giving up with ErrLockNotAcquired.
+	// Defaults to one minute.
+	MaxWait time.Duration
+
+	// Interval is the time to wait after the first attempt, before trying again.
+	// Defaults to 5 seconds.
+	Interval time.Duration
+
+	// Backoff multiplies the interval after every attempt, for exponential backoff. Values below 1 keep the
+	// interval fixed.
+	// Defaults to 1.
+	Backoff float64
+
+	// MaxInterval is the longest interval between two attempts. Zero means that the interval is only limited by
+	// MaxWait.
+	MaxInterval time.Duration
+
+	// Jitter randomizes every interval by up to this fraction of it, in either direction, so that instances that
+	// started at the same time do not try at the same time. 0.2 makes an interval of 5 seconds anything from 4 to 6
+	// seconds.
+	// Defaults to 0.
+	Jitter float64
+
+	// FailFast makes Migrate return ErrLockNotAcquired at once if another instance holds the lock, without waiting.
+	FailFast bool
+
+	// Heartbeat is how often the instance holding the lock table renews its heartbeat, which keeps the lock from
+	// being removed as stale by other instances during long migrations. It is not used with a Locker.
+	// Defaults to a third of Config.LockTimeoutMinutes.
+	Heartbeat time.Duration
+}
+
+func (o LockPolicyOption) apply(service *Service) {
+	service.lockPolicy = o
+}
+
+// withDefaults returns the policy, with the defaults for the fields that are not set.
+func (o LockPolicyOption) withDefaults() LockPolicyOption {
+	if o.MaxWait <= 0 {
+		o.MaxWait = time.Minute
+	}
+
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Second
+	}
+
+	if o.Backoff < 1 {
+		o.Backoff = 1
+	}
+
+	return o
+}
+
+// interval returns the time to wait after the given attempt, starting at 1, before jitter is applied.
+func (o LockPolicyOption) interval(attempt int) time.Duration {
+	d := float64(o.Interval) * math.Pow(o.Backoff, float64(attempt-1))
+
+	if o.MaxInterval > 0 && d > float64(o.MaxInterval) {
+		return o.MaxInterval
+	}
+
+	if d > float64(o.MaxWait) {
+		return o.MaxWait
+	}
+
+	return time.Duration(d)
+}
+
+// jittered returns d, randomized by up to Jitter of it in either direction.
+func (o LockPolicyOption) jittered(d time.Duration) time.Duration {
+	if o.Jitter <= 0 {
+		return d
+	}
+
+	return time.Duration(float64(d) * (1 + o.Jitter*(2*rand.Float64()-1))) //nolint:gosec
+}
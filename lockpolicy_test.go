@@ -0,0 +1,98 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockPolicyOption_interval(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy LockPolicyOption
+		want   []time.Duration
+	}{
+		{
+			name:   "defaults",
+			policy: LockPolicyOption{},
+			want:   []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:   "backoff",
+			policy: LockPolicyOption{Interval: time.Second, Backoff: 2},
+			want:   []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second},
+		},
+		{
+			name:   "backoff up to max interval",
+			policy: LockPolicyOption{Interval: time.Second, Backoff: 3, MaxInterval: 5 * time.Second},
+			want:   []time.Duration{time.Second, 3 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:   "backoff up to max wait",
+			policy: LockPolicyOption{MaxWait: 10 * time.Second, Interval: 4 * time.Second, Backoff: 2},
+			want:   []time.Duration{4 * time.Second, 8 * time.Second, 10 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := tt.policy.withDefaults()
+
+			for i, want := range tt.want {
+				assert.Equal(t, want, policy.interval(i+1), "attempt %d", i+1)
+			}
+		})
+	}
+}
+
+func TestLockPolicyOption_jittered(t *testing.T) {
+	policy := LockPolicyOption{Jitter: 0.2}.withDefaults()
+
+	for i := 0; i < 100; i++ {
+		d := policy.jittered(5 * time.Second)
+		assert.GreaterOrEqual(t, d, 4*time.Second)
+		assert.LessOrEqual(t, d, 6*time.Second)
+	}
+
+	assert.Equal(t, 5*time.Second, LockPolicyOption{}.jittered(5*time.Second))
+}
+
+func TestService_Migrate_LockPolicy(t *testing.T) {
+	lockDB, err := sql.Open(string(Sqlite), filepath.Join(t.TempDir(), "mig.lock"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	defer func() { _ = lockDB.Close() }()
+
+	// Another instance holds the lock.
+	other := NewSQLiteLocker(lockDB)
+
+	locked, err := other.TryLock(context.Background())
+	if !assert.NoError(t, err) || !assert.True(t, locked) {
+		return
+	}
+
+	defer func() { _ = other.Unlock(context.Background()) }()
+
+	db := openTestDB(t)
+	newService := func(policy LockPolicyOption) *Service {
+		return newTestService(t, db, fstest.MapFS{}, LockerOption{Locker: NewSQLiteLocker(lockDB)}, policy)
+	}
+
+	res, err := newService(LockPolicyOption{FailFast: true}).MigrateWithResult(context.Background())
+	assert.ErrorIs(t, err, ErrLockNotAcquired)
+	assert.ErrorContains(t, err, "migration already in progress. failed to get lock, gave up after")
+	assert.Equal(t, 1, res.LockAttempts)
+
+	res, err = newService(LockPolicyOption{MaxWait: 50 * time.Millisecond, Interval: 10 * time.Millisecond, Backoff: 2}).
+		MigrateWithResult(context.Background())
+	assert.ErrorIs(t, err, ErrLockNotAcquired)
+	assert.GreaterOrEqual(t, res.LockAttempts, 3)
+	assert.GreaterOrEqual(t, res.LockWait, 50*time.Millisecond)
+}
@@ -0,0 +1,158 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LockInfo tells whether the lock table holds the migration lock, and who holds it, as returned by LockStatus.
+type LockInfo struct {
+	// Held is set if the lock is held. The other fields are only set if it is.
+	Held bool
+
+	// Owner is the token identifying the holder of the lock. It is unique to each lock taken.
+	Owner string
+
+	// Hostname is the hostname of the instance holding the lock.
+	Hostname string
+
+	// PID is the process ID of the instance holding the lock.
+	PID int
+
+	// Since is when the lock was taken.
+	Since time.Time
+
+	// Heartbeat is when the holder of the lock last renewed it.
+	Heartbeat time.Time
+}
+
+// LockStatus returns whether the migration lock is held, by whom and since when. It reads the lock table, so it returns
+// ErrNoLockTable if a Locker is set. If the lock table has not been created yet, the lock is not held.
+func (s *Service) LockStatus() (LockInfo, error) {
+	return s.lockStatus(context.Background())
+}
+
+// ForceUnlock removes the migration lock, whoever holds it. It is meant for clearing a lock left behind by an instance
+// that was killed while migrating. If that instance is still running, another instance may start migrating at the same
+// time, so a warning is logged with the holder of the lock. Only the lock that was inspected is removed, so if it is
+// released or taken over by another instance in the meantime, nothing is removed and a warning is logged instead. It
+// returns ErrNoLockTable if a Locker is set.
+func (s *Service) ForceUnlock() error {
+	ctx := context.Background()
+
+	info, hasOwner, err := s.readLock(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !info.Held {
+		s.logger.Info("migration lock is not held, nothing to unlock")
+
+		return nil
+	}
+
+	s.logger.Warn(fmt.Sprintf("forcing removal of migration lock held by %s (host %s, pid %d) since %s",
+		info.Owner, info.Hostname, info.PID, info.Since.Format(time.RFC3339)))
+
+	query := fmt.Sprintf("delete from %s where id = 1", s.quoteTable(s.migrationLockTable))
+
+	var args []any
+
+	switch {
+	case info.Owner != "":
+		query += " and owner = " + s.param(1)
+		args = append(args, info.Owner)
+	case hasOwner:
+		// Taken by an instance from before locks had owners.
+		query += " and owner is null"
+	}
+
+	res, err := s.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to remove migration lock: %w", err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		s.logger.Warn(fmt.Sprintf("migration lock held by %s was released or taken over before it could be removed, "+
+			"it is left as is", info.Owner))
+	}
+
+	return nil
+}
+
+func (s *Service) lockStatus(ctx context.Context) (LockInfo, error) {
+	info, _, err := s.readLock(ctx)
+
+	return info, err
+}
+
+// readLock reads the lock table. It also tells whether the lock table has the owner column, which is only added by
+// Migrate.
+func (s *Service) readLock(ctx context.Context) (info LockInfo, hasOwner bool, err error) {
+	if s.locker != nil {
+		return LockInfo{}, false, ErrNoLockTable
+	}
+
+	exists, err := s.tableExists(ctx, s.migrationLockTable)
+	if err != nil {
+		return LockInfo{}, false, fmt.Errorf("failed to check for lock table: %w", err)
+	}
+
+	if !exists {
+		return LockInfo{}, false, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("select * from %s", s.quoteTable(s.migrationLockTable)))
+	if err != nil {
+		return LockInfo{}, false, fmt.Errorf("failed to read lock table: %w", err)
+	}
+
+	defer func() { _ = rows.Close() }()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return LockInfo{}, false, err
+	}
+
+	for _, column := range columns {
+		hasOwner = hasOwner || strings.EqualFold(column, "owner")
+	}
+
+	var (
+		owner, hostname      sql.NullString
+		pid                  sql.NullInt64
+		createdAt, heartbeat sql.NullTime
+	)
+
+	for rows.Next() {
+		dest := scanDest(columns, map[string]any{
+			"created_at":   &createdAt,
+			"owner":        &owner,
+			"hostname":     &hostname,
+			"pid":          &pid,
+			"heartbeat_at": &heartbeat,
+		})
+
+		if err := rows.Scan(dest...); err != nil {
+			return LockInfo{}, false, err
+		}
+
+		info = LockInfo{
+			Held:      true,
+			Owner:     owner.String,
+			Hostname:  hostname.String,
+			PID:       int(pid.Int64),
+			Since:     createdAt.Time,
+			Heartbeat: heartbeat.Time,
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return LockInfo{}, false, err
+	}
+
+	return info, hasOwner, nil
+}
@@ -0,0 +1,89 @@
+package migration
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestService_LockStatus(t *testing.T) {
+	s := New(openTestDB(t), ZapOption{Logger: zap.NewNop()})
+
+	// The lock table has not been created yet.
+	info, err := s.LockStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, LockInfo{}, info)
+	assert.NoError(t, s.ForceUnlock())
+
+	if !assert.NoError(t, s.createMigrationTables(context.Background())) {
+		return
+	}
+
+	// A lock left behind by an instance that was killed.
+	locker := &tableLocker{s: s}
+
+	locked, err := locker.TryLock(context.Background())
+	if !assert.NoError(t, err) || !assert.True(t, locked) {
+		return
+	}
+
+	close(locker.stop)
+	<-locker.done
+
+	info, err = s.LockStatus()
+	if assert.NoError(t, err) {
+		assert.True(t, info.Held)
+		assert.Equal(t, locker.owner, info.Owner)
+		assert.Equal(t, os.Getpid(), info.PID)
+		assert.False(t, info.Since.IsZero())
+		assert.False(t, info.Heartbeat.IsZero())
+	}
+
+	assert.NoError(t, s.ForceUnlock())
+
+	info, err = s.LockStatus()
+	assert.NoError(t, err)
+	assert.False(t, info.Held)
+
+	locker = &tableLocker{s: s}
+	locked, err = locker.TryLock(context.Background())
+	assert.NoError(t, err)
+	assert.True(t, locked, "the lock can be taken again")
+	assert.NoError(t, locker.Unlock(context.Background()))
+}
+
+func TestService_ForceUnlock_NoOwner(t *testing.T) {
+	s := New(openTestDB(t), ZapOption{Logger: zap.NewNop()})
+
+	if !assert.NoError(t, s.createMigrationTables(context.Background())) {
+		return
+	}
+
+	// A lock taken by an instance from before locks had owners.
+	_, err := s.db.Exec("insert into migration_lock(id) values(1)")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	info, err := s.LockStatus()
+	assert.NoError(t, err)
+	assert.True(t, info.Held)
+	assert.Empty(t, info.Owner)
+
+	assert.NoError(t, s.ForceUnlock())
+
+	info, err = s.LockStatus()
+	assert.NoError(t, err)
+	assert.False(t, info.Held)
+}
+
+func TestService_LockStatus_Locker(t *testing.T) {
+	s := New(openTestDB(t), ZapOption{Logger: zap.NewNop()}, LockerOption{Locker: failingLocker{}})
+
+	_, err := s.LockStatus()
+	assert.ErrorIs(t, err, ErrNoLockTable)
+	assert.ErrorIs(t, s.ForceUnlock(), ErrNoLockTable)
+}
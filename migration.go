@@ -2,6 +2,7 @@ package migration
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5" //nolint:gosec
 	"database/sql"
 	"encoding/hex"
@@ -9,96 +10,231 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"sort"
 	"strings"
 	"time"
 )
 
 type migration struct {
-	ID       string    // unique ID for the migration. Also name of file
-	Date     time.Time // date and time the migration was applied, default current_timestamp
-	Checksum string    // makes sure that migrations do not change over time
+	ID        string    // unique ID for the migration. Also name of file
+	Date      time.Time // date and time the migration was applied, default current_timestamp
+	Checksum  string    // makes sure that migrations do not change over time
+	Variant   string    // dialect of the variant that was applied, empty if the file was not a variant
+	Baselined bool      // set if the migration was recorded by Baseline, without being applied
 }
 
 // Migrate applies all non applied migrations in the migration folder to the database, in alphabetical order.
 // It also checks that previously applied migrations have not changed using md5 checksums.
 func (s *Service) Migrate() error {
-	err := s.createMigrationTables()
+	return s.MigrateContext(context.Background())
+}
+
+// MigrateContext is like Migrate, but stops as soon as ctx is cancelled. The context is used while waiting for the
+// lock, when applying SQL statements and when applying func migrations. If ctx is cancelled while a migration is
+// being applied, that migration is rolled back and ctx.Err() is returned. Migrations with the no-transaction directive
+// cannot be rolled back, so a *StatementError telling which statement was interrupted is returned for them instead.
+func (s *Service) MigrateContext(ctx context.Context) error {
+	_, err := s.MigrateWithResult(ctx)
+
+	return err
+}
+
+// MigrateWithResult is like MigrateContext, but also returns what was done with each migration. The result is
+// returned even if the migration fails, and then holds the migrations handled before the failure.
+func (s *Service) MigrateWithResult(ctx context.Context) (*MigrateResult, error) {
+	return s.run(ctx, "")
+}
+
+// MigrateTo is like Migrate, but only applies pending migrations up to and including the migration with the given ID.
+// Pending migrations after it are left for a later call, and so are repeatable migrations, which may depend on them.
+// The checksums of all applied migrations that are not repeatable are still verified.
+// An error wrapping ErrMigrationNotFound is returned if there is no versioned migration with the given ID.
+func (s *Service) MigrateTo(id string) error {
+	return s.MigrateToContext(context.Background(), id)
+}
+
+// MigrateToContext is like MigrateTo, but stops as soon as ctx is cancelled, like MigrateContext.
+func (s *Service) MigrateToContext(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("%w: empty target", ErrMigrationNotFound)
+	}
+
+	_, err := s.run(ctx, id)
+
+	return err
+}
+
+// run migrates the database, up to and including target if it is not empty.
+func (s *Service) run(ctx context.Context, target string) (*MigrateResult, error) {
+	res := &MigrateResult{}
+	start := time.Now()
+
+	defer func() { res.Duration = time.Since(start) }()
+
+	release, err := s.prepare(ctx, res)
 	if err != nil {
-		return fmt.Errorf("failed to create migration tables: %w", err)
+		return res, err
 	}
 
-	locked, release := s.lock()
 	defer release()
 
-	if !locked {
-		return errors.New("migration already in progress. failed to get lock")
+	if err := s.migrate(ctx, res, target); err != nil {
+		// A migration without transaction cannot be rolled back, so that error is kept to tell what was applied.
+		var stmtErr *StatementError
+		if ctx.Err() != nil && (!errors.As(err, &stmtErr) || !stmtErr.NoTransaction) {
+			return res, ctx.Err()
+		}
+
+		return res, err
+	}
+
+	return res, nil
+}
+
+// prepare creates the migration tables and takes the lock. The time spent waiting for the lock and the number of
+// attempts are recorded in res. The returned func releases the lock.
+func (s *Service) prepare(ctx context.Context, res *MigrateResult) (func(), error) {
+	if err := s.createMigrationTables(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, fmt.Errorf("failed to create migration tables: %w", err)
+	}
+
+	lockStart := time.Now()
+	release, attempts, err := s.lock(ctx)
+	res.LockWait = time.Since(lockStart)
+	res.LockAttempts = attempts
+
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		return nil, err
 	}
 
-	appliedMigs, err := s.fetchAppliedMigrations()
+	s.logger.Info(fmt.Sprintf("took migration lock after %s (attempts: %d)",
+		res.LockWait.Round(time.Millisecond), attempts))
+
+	return release, nil
+}
+
+func (s *Service) migrate(ctx context.Context, res *MigrateResult, target string) error {
+	appliedMigs, err := s.fetchAppliedMigrations(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to fetch applied migrations: %w", err)
 	}
 
-	availableMigs, err := s.listMigrations()
+	files, err := s.listMigrations(appliedMigs)
 	if err != nil {
 		return fmt.Errorf("failed to list available migrations: %w", err)
 	}
 
-	sort.Strings(availableMigs)
+	if _, ok := files[target]; target != "" && (!ok || s.isRepeatable(target)) {
+		return fmt.Errorf("%w: %s", ErrMigrationNotFound, target)
+	}
 
-	for _, mig := range availableMigs {
-		chkSum, applied := appliedMigs[mig]
+	for _, mig := range s.orderedIDs(files) {
+		repeatable := s.isRepeatable(mig)
 
-		if !applied {
-			funcMigration, err := s.shouldApplyFuncMigration(mig)
-			if err != nil {
-				return fmt.Errorf("failed to determine if func migration should be applied: %w", err)
-			}
+		if _, applied := appliedMigs[mig]; !applied && !repeatable && target != "" && mig > target {
+			// Held back until a later migration.
+			continue
+		}
 
-			if funcMigration != nil {
-				// Code based migration not yet applied was found.
-				if err := s.applyFuncMigration(funcMigration); err != nil {
-					return fmt.Errorf("failed to apply func migration %s: %w", mig, err)
-				}
+		if repeatable && target != "" {
+			// Repeatable migrations may depend on migrations held back after the target, so they are left for Migrate.
+			continue
+		}
 
-				continue
-			}
+		start := time.Now()
 
-			// Explicitly require SQL-type migrations to have .sql suffix to
-			// allow for go files in migrations directory that e.g. may be added
-			// during pipeline execution or as test files for func migrations.
-			if !strings.HasSuffix(mig, ".sql") {
-				s.logger.Info(fmt.Sprintf("Skipping file %s.", mig))
+		var action Action
+		if repeatable {
+			action, err = s.migrateRepeatable(ctx, mig, files[mig], appliedMigs)
+		} else {
+			action, err = s.migrateOne(ctx, mig, files[mig], appliedMigs)
+		}
 
-				continue
-			}
+		variant := s.variantOf(mig, files[mig])
 
-			// SQL based migration not yet applied was found.
-			if err := s.applySQLMigration(mig); err != nil {
-				return fmt.Errorf("failed to apply migration %s: %w", mig, err)
-			}
-		} else {
-			c, err := s.fileHash(fmt.Sprintf("%s/%s", s.migrationFolder, mig))
-			if err != nil {
-				return fmt.Errorf("failed to get checksum for file %s: %w", mig, err)
-			}
+		res.Migrations = append(res.Migrations,
+			MigrationResult{ID: mig, Variant: variant, Action: action, Duration: time.Since(start)})
 
-			if strings.HasSuffix(mig, ".go") {
-				c, err = s.checksum(bytes.NewReader([]byte(mig)))
-				if err != nil {
-					return fmt.Errorf("failed to create checksum for migration: %w", err)
-				}
-			}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
 
-			if c != chkSum {
-				return fmt.Errorf("file %s has been updated since it was migrated, "+
-					"wanted checksum '%s', got '%s'", mig, chkSum, c)
+// migrateOne applies a single migration from file if it has not been applied yet, and otherwise verifies its checksum.
+func (s *Service) migrateOne(ctx context.Context, mig, file string, appliedMigs map[string]migration) (Action, error) {
+	appliedMig, applied := appliedMigs[mig]
+
+	if !applied {
+		funcMigration, err := s.shouldApplyFuncMigration(mig)
+		if err != nil {
+			return ActionFailed, fmt.Errorf("failed to determine if func migration should be applied: %w", err)
+		}
+
+		if funcMigration != nil {
+			// Code based migration not yet applied was found.
+			if err := s.applyFuncMigration(ctx, funcMigration); err != nil {
+				return ActionFailed, fmt.Errorf("failed to apply func migration %s: %w", mig, err)
 			}
+
+			return ActionApplied, nil
+		}
+
+		// Explicitly require SQL-type migrations to have .sql or .sql.tmpl
+		// suffix to allow for go files in migrations directory that e.g. may
+		// be added during pipeline execution or as test files for func
+		// migrations.
+		if !isSQLMigration(mig) {
+			s.logger.Info(fmt.Sprintf("Skipping file %s.", mig))
+
+			return ActionSkipped, nil
 		}
+
+		// SQL based migration not yet applied was found.
+		if err := s.applySQLMigration(ctx, mig, file, false); err != nil {
+			return ActionFailed, fmt.Errorf("failed to apply migration %s: %w", mig, err)
+		}
+
+		return ActionApplied, nil
 	}
 
-	return nil
+	c, err := s.migrationChecksum(file)
+	if err != nil {
+		return ActionFailed, err
+	}
+
+	if c != appliedMig.Checksum {
+		return ActionFailed, &ChecksumMismatchError{ID: mig, Expected: appliedMig.Checksum, Actual: c}
+	}
+
+	return ActionVerified, nil
+}
+
+// migrationChecksum returns the checksum of a file in the migration folder, as it is stored in the migration table
+// once the migration has been applied.
+func (s *Service) migrationChecksum(file string) (string, error) {
+	c, err := s.fileHash(fmt.Sprintf("%s/%s", s.migrationFolder, file))
+	if err != nil {
+		return "", fmt.Errorf("failed to get checksum for file %s: %w", file, err)
+	}
+
+	if strings.HasSuffix(file, ".go") {
+		c, err = s.checksum(bytes.NewReader([]byte(file)))
+		if err != nil {
+			return "", fmt.Errorf("failed to create checksum for migration: %w", err)
+		}
+	}
+
+	return c, nil
 }
 
 // shouldApplyFuncMigration checks if a migration filename matches that of a
@@ -106,16 +242,26 @@ func (s *Service) Migrate() error {
 // extension. Returns the provided implementation if one exists and nil if no
 // such migration exists.
 func (s *Service) shouldApplyFuncMigration(name string) (FuncMigration, error) {
+	fm, err := s.findFuncMigration(name)
+	if err == nil && fm == nil && strings.HasSuffix(name, ".go") {
+		s.logger.Info(fmt.Sprintf("Ignoring possible migration file, "+
+			"no filename declaration matching %s was found in provided func "+
+			"migrations.", name))
+	}
+
+	return fm, err
+}
+
+// findFuncMigration is like shouldApplyFuncMigration, but does not log the
+// .go files without a declared func migration. It is used when only reporting
+// the status, which may be polled.
+func (s *Service) findFuncMigration(name string) (FuncMigration, error) {
 	if !strings.HasSuffix(name, ".go") {
 		return nil, nil
 	}
 
 	fm, exists := s.funcMigrations[name]
 	if !exists {
-		s.logger.Info(fmt.Sprintf("Ignoring possible migration file, "+
-			"no filename declaration matching %s was found in provided func "+
-			"migrations.", name))
-
 		return nil, nil
 	}
 
@@ -131,69 +277,242 @@ func (s *Service) shouldApplyFuncMigration(name string) (FuncMigration, error) {
 	return fm, nil
 }
 
-func (s *Service) createMigrationTables() error {
-	_, err := s.db.Exec(fmt.Sprintf(`create table if not exists %s (
-		id varchar(255) primary key,
-		date timestamp default current_timestamp,
-		checksum varchar(255));`,
-		s.migrationTable))
+func (s *Service) createMigrationTables(ctx context.Context) error {
+	if err := s.validateNames(); err != nil {
+		return err
+	}
+
+	if err := s.checkLowerCaseTables(ctx); err != nil {
+		return err
+	}
+
+	if s.createSchema {
+		if err := s.createSchemas(ctx); err != nil {
+			return err
+		}
+	}
+
+	d := s.currentDialect()
+
+	_, err := s.db.ExecContext(ctx, d.CreateTable(s.quoteTable(s.migrationTable), []string{
+		"id varchar(255) primary key",
+		"date " + d.TimestampType() + " default current_timestamp",
+		"checksum varchar(255)",
+		"variant varchar(255)",
+		"baselined " + d.BooleanType(),
+	}))
 	if err != nil {
 		return err
 	}
 
-	_, err = s.db.Exec(fmt.Sprintf(`create table if not exists %s (
-		id integer primary key,
-		created_at timestamp default current_timestamp);`,
-		s.migrationLockTable))
+	// Tables created by earlier versions lack the columns added since.
+	if err = s.addColumn(ctx, s.migrationTable, "variant", "varchar(255)"); err != nil {
+		return err
+	}
 
-	return err
-}
+	if err = s.addColumn(ctx, s.migrationTable, "baselined", d.BooleanType()); err != nil {
+		return err
+	}
 
-func (s *Service) lock() (bool, func()) {
-	release := func() {
-		_, _ = s.db.Exec(fmt.Sprintf("delete from %s", s.migrationLockTable))
+	_, err = s.db.ExecContext(ctx, d.CreateTable(s.quoteTable(s.migrationLockTable), []string{
+		"id integer primary key",
+		"created_at " + d.TimestampType() + " default current_timestamp",
+		"owner varchar(255)",
+		"hostname varchar(255)",
+		"pid integer",
+		"heartbeat_at " + d.TimestampType() + " null",
+	}))
+	if err != nil {
+		return err
+	}
+
+	for _, column := range []struct{ name, definition string }{
+		{"owner", "varchar(255)"},
+		{"hostname", "varchar(255)"},
+		{"pid", "integer"},
+		{"heartbeat_at", d.TimestampType() + " null"},
+	} {
+		if err = s.addColumn(ctx, s.migrationLockTable, column.name, column.definition); err != nil {
+			return err
+		}
 	}
 
-	for i := 0; i < 12; i++ {
-		_, err := s.db.Exec(fmt.Sprintf("insert into %s(id) values(1)", s.migrationLockTable))
-		if err == nil {
-			return true, release
+	return nil
+}
+
+// lock tries to take the migration lock, as often and for as long as the lock policy allows. It gives up early if ctx
+// is cancelled, or if the locker fails. It returns a func that releases the lock, and the number of attempts made.
+func (s *Service) lock(ctx context.Context) (func(), int, error) {
+	policy := s.lockPolicy.withDefaults()
+	locker := s.currentLocker()
+	start := time.Now()
+
+	for attempt := 1; ; attempt++ {
+		locked, err := locker.TryLock(ctx)
+		if err != nil {
+			return nil, attempt, fmt.Errorf("failed to take migration lock: %w", err)
+		}
+
+		if locked {
+			return func() {
+				// The lock must be released even if ctx has been cancelled.
+				if err := locker.Unlock(context.Background()); err != nil {
+					s.logger.Warn(fmt.Sprintf("failed to release migration lock: %v", err))
+				}
+			}, attempt, nil
+		}
+
+		wait := policy.jittered(policy.interval(attempt))
+		if remaining := policy.MaxWait - time.Since(start); wait > remaining {
+			wait = remaining
+		}
+
+		if policy.FailFast || wait <= 0 {
+			return nil, attempt, fmt.Errorf("%w, gave up after %s (attempts: %d)",
+				ErrLockNotAcquired, time.Since(start).Round(time.Millisecond), attempt)
+		}
+
+		s.logger.Info(fmt.Sprintf("waiting for migration lock, trying again in %s (attempt %d)",
+			wait.Round(time.Millisecond), attempt+1))
+
+		select {
+		case <-ctx.Done():
+			return nil, attempt, ctx.Err()
+		case <-time.After(wait):
 		}
+	}
+}
+
+// addColumn adds a column to a table, unless it already has it.
+func (s *Service) addColumn(ctx context.Context, table, column, definition string) error {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("select %s from %s where 1 = 0", column, s.quoteTable(table)))
+	if err == nil {
+		return rows.Close()
+	}
+
+	if _, err = s.db.ExecContext(ctx, fmt.Sprintf("alter table %s add %s %s", s.quoteTable(table), column,
+		definition)); err != nil {
+		return fmt.Errorf("failed to add %s column to %s: %w", column, table, err)
+	}
+
+	return nil
+}
+
+// tableExists reports whether a table exists, with the table existence query of the dialect. Databases that do not
+// support the query, such as those of the generic dialect without information_schema, are probed with a query that
+// returns no rows instead.
+func (s *Service) tableExists(ctx context.Context, table string) (bool, error) {
+	if err := s.validateNames(); err != nil {
+		return false, err
+	}
+
+	query, args := s.tableExistsQuery(table)
+
+	count := 0
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&count); err == nil {
+		return count > 0, nil
+	}
 
-		s.logger.Info("waiting for migration lock")
-		time.Sleep(5 * time.Second)
+	// Make sure that a failing probe is caused by the table, and not by the connection.
+	if err := s.db.PingContext(ctx); err != nil {
+		return false, err
+	}
 
-		_, _ = s.db.Exec(
-			fmt.Sprintf("delete from %s where created_at < timestampadd(minute, %d, current_timestamp)",
-				s.migrationLockTable, -s.lockTimeoutMinutes))
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("select 1 from %s where 1 = 0", s.quoteTable(table)))
+	if err != nil {
+		return false, nil //nolint:nilerr
 	}
 
-	return false, func() {}
+	return true, rows.Close()
 }
 
-func (s *Service) fetchAppliedMigrations() (map[string]string, error) {
-	var mig migration
+func (s *Service) fetchAppliedMigrations(ctx context.Context) (map[string]migration, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("select * from %s", s.quoteTable(s.migrationTable)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = rows.Close() }()
 
-	rows, err := s.db.Query(fmt.Sprintf("select * from %s", s.migrationTable))
+	columns, err := rows.Columns()
 	if err != nil {
 		return nil, err
 	}
 
-	migMap := make(map[string]string)
+	migMap := make(map[string]migration)
 
 	for rows.Next() {
-		err = rows.Scan(&mig.ID, &mig.Date, &mig.Checksum)
-		migMap[mig.ID] = mig.Checksum
+		var (
+			mig       migration
+			variant   sql.NullString
+			baselined sql.NullBool
+		)
+
+		dest := scanDest(columns, map[string]any{
+			"id":        &mig.ID,
+			"date":      &mig.Date,
+			"checksum":  &mig.Checksum,
+			"variant":   &variant,
+			"baselined": &baselined,
+		})
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		mig.Variant = variant.String
+		mig.Baselined = baselined.Bool
+		migMap[mig.ID] = mig
 	}
 
-	if err != nil {
+	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
 	return migMap, nil
 }
 
-func (s *Service) listMigrations() ([]string, error) {
+// scanDest returns the destinations to scan a row with the given columns into, by looking up each column in fields.
+// The columns are scanned by name, as columns added since a table was created are only added by Migrate. Columns that
+// are not in fields are discarded.
+func scanDest(columns []string, fields map[string]any) []any {
+	dest := make([]any, len(columns))
+
+	for i, column := range columns {
+		if field, ok := fields[strings.ToLower(column)]; ok {
+			dest[i] = field
+		} else {
+			dest[i] = new(any)
+		}
+	}
+
+	return dest
+}
+
+// fetchAppliedMigrationsIfExists is like fetchAppliedMigrations, but returns no migrations instead of failing if the
+// migration table has not been created yet.
+func (s *Service) fetchAppliedMigrationsIfExists(ctx context.Context) (map[string]migration, error) {
+	exists, err := s.tableExists(ctx, s.migrationTable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for migration table: %w", err)
+	}
+
+	if !exists {
+		return map[string]migration{}, nil
+	}
+
+	appliedMigs, err := s.fetchAppliedMigrations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch applied migrations: %w", err)
+	}
+
+	return appliedMigs, nil
+}
+
+// listMigrations returns the migrations in the migration folder, as a map from their ID to the file to apply. Of the
+// dialect variants of a migration, only the one for the current dialect is listed. The applied migrations tell which
+// files were recorded before dialect variants were enabled.
+func (s *Service) listMigrations(appliedMigs map[string]migration) (map[string]string, error) {
 	files, err := fs.ReadDir(s.fs, s.migrationFolder)
 	if err != nil {
 		return nil, err
@@ -207,76 +526,161 @@ func (s *Service) listMigrations() ([]string, error) {
 		}
 	}
 
-	return fileNames, nil
+	return s.selectVariants(fileNames, appliedMigs)
 }
 
-func (s *Service) applySQLMigration(mig string) error {
-	c, err := s.fileHash(fmt.Sprintf("%s/%s", s.migrationFolder, mig))
+// applySQLMigration applies a SQL migration from file. If reapply is set, the migration is a repeatable migration that
+// has been applied before, and its record is updated instead of inserted.
+func (s *Service) applySQLMigration(ctx context.Context, mig, file string, reapply bool) error {
+	c, err := s.fileHash(fmt.Sprintf("%s/%s", s.migrationFolder, file))
 	if err != nil {
-		return fmt.Errorf("failed to get checksum for file %s: %w", mig, err)
+		return fmt.Errorf("failed to get checksum for file %s: %w", file, err)
 	}
 
-	file, err := fs.ReadFile(s.fs, fmt.Sprintf("%s/%s", s.migrationFolder, mig))
-
+	sqlMig, err := s.readSQLMigration(mig, file)
 	if err != nil {
-		return fmt.Errorf("failed to read file %s: %w", mig, err)
+		return err
 	}
 
-	requests := strings.Split(string(file), ";")
+	if sqlMig.noTransaction {
+		return s.applySQLMigrationWithoutTx(ctx, mig, c, sqlMig, reapply)
+	}
 
-	s.logger.Info(fmt.Sprintf("applying migration: %s", mig))
+	if sqlMig.variant != "" {
+		s.logger.Info(fmt.Sprintf("applying migration: %s (%s variant)", mig, sqlMig.variant))
+	} else {
+		s.logger.Info(fmt.Sprintf("applying migration: %s", mig))
+	}
 
 	// MySQL transactions will not work with ALTER TABLE and other DDL statements. See this post for more details:
 	// https://stackoverflow.com/questions/22806261/can-i-use-transactions-with-alter-table
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
-	for _, request := range requests {
-		if strings.Trim(request, " \n\r") == "" {
-			continue
+	for i, stmt := range sqlMig.statements {
+		_, err = tx.ExecContext(ctx, stmt.SQL)
+		if err != nil {
+			s.rollback(tx)
+
+			return newStatementError(mig, sqlMig, i, stmt, err)
 		}
+	}
 
-		_, err = tx.Exec(request)
-		if err != nil {
-			if err := tx.Rollback(); err != nil {
-				s.logger.Warn("rollback failed")
+	if err = s.recordMigration(ctx, tx, c, mig, sqlMig.variant, reapply); err != nil {
+		s.rollback(tx)
+
+		return fmt.Errorf("failed to insert migration: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// applySQLMigrationWithoutTx applies a migration with the no-transaction directive. The statements are executed one
+// by one on a single connection, and the migration is recorded once all of them have succeeded. Statements that
+// succeeded before a failing one are not rolled back.
+func (s *Service) applySQLMigrationWithoutTx(ctx context.Context, mig, checksum string, sqlMig sqlMigration,
+	reapply bool,
+) error {
+	s.logger.Info(fmt.Sprintf("applying migration without transaction: %s", mig))
+
+	// Session settings made by the statements must stay in effect for the statements after them.
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	for i, stmt := range sqlMig.statements {
+		if _, err := conn.ExecContext(ctx, stmt.SQL); err != nil {
+			if i > 0 {
+				s.logger.Warn(fmt.Sprintf("migration %s failed after %d of %d statements were applied, "+
+					"which have not been rolled back", mig, i, len(sqlMig.statements)))
 			}
 
-			return fmt.Errorf("failing statement [%s]: %w", request, err)
+			stmtErr := newStatementError(mig, sqlMig, i, stmt, err)
+			stmtErr.NoTransaction = true
+
+			return stmtErr
 		}
 	}
 
-	if err = s.insertCompletedMigration(tx, c, mig); err != nil {
-		return fmt.Errorf("failed to insert migration: %w", err)
+	if err := s.recordMigration(ctx, conn, checksum, mig, sqlMig.variant, reapply); err != nil {
+		s.logger.Warn(fmt.Sprintf("all statements of migration %s were applied, but it could not be recorded", mig))
+
+		return fmt.Errorf("failed to insert migration applied without transaction: %w", err)
 	}
 
+	return nil
+}
+
+// isSQLMigration reports whether a file in the migration folder is a SQL migration, or a SQL migration template.
+func isSQLMigration(name string) bool {
+	return strings.HasSuffix(name, ".sql") || strings.HasSuffix(name, templateSuffix)
+}
+
+// sqlMigration is a parsed SQL migration file.
+type sqlMigration struct {
+	file          string
+	variant       string
+	src           string
+	rendered      bool
+	statements    []Statement
+	noTransaction bool
+}
+
+// readSQLMigration reads name, the SQL migration file applied for mig, its directives and the statements to execute.
+// Templates are rendered first, so the statements and their offsets are those of the rendered SQL.
+func (s *Service) readSQLMigration(mig, name string) (sqlMigration, error) {
+	file, err := fs.ReadFile(s.fs, fmt.Sprintf("%s/%s", s.migrationFolder, name))
 	if err != nil {
-		if err := tx.Rollback(); err != nil {
-			s.logger.Warn("rollback failed")
+		return sqlMigration{}, fmt.Errorf("failed to read file %s: %w", name, err)
+	}
+
+	if strings.HasSuffix(name, templateSuffix) {
+		file, err = s.renderTemplate(name, file)
+		if err != nil {
+			return sqlMigration{}, fmt.Errorf("failed to render template %s: %w", name, err)
 		}
+	}
 
-		return err
+	statements, err := s.currentDialect().SplitStatements(string(file))
+	if err != nil {
+		return sqlMigration{}, fmt.Errorf("failed to split file %s: %w", name, err)
 	}
 
-	return tx.Commit()
+	return sqlMigration{
+		file:          name,
+		variant:       s.variantOf(mig, name),
+		src:           string(file),
+		rendered:      strings.HasSuffix(name, templateSuffix),
+		statements:    statements,
+		noTransaction: hasDirective(string(file), noTransactionDirective),
+	}, nil
 }
 
-func (s *Service) applyFuncMigration(fm FuncMigration) error {
+func (s *Service) applyFuncMigration(ctx context.Context, fm FuncMigration) error {
 	s.logger.Info(fmt.Sprintf("applying migration: %s", fm.Filename()))
 
-	tx, err := s.db.Begin()
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
-	if err := fm.Apply(tx); err != nil {
-		if err := tx.Rollback(); err != nil {
+	if cfm, ok := fm.(FuncMigrationContext); ok {
+		err = cfm.ApplyContext(ctx, tx)
+	} else {
+		err = fm.Apply(tx)
+	}
+
+	if err != nil {
+		if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
 			return fmt.Errorf("failed to rollback failed migration %w", err)
 		}
 
-		return fmt.Errorf("failed to apply migration: %w", err)
+		return &FuncMigrationError{ID: fm.Filename(), Err: err}
 	}
 
 	// Checksum of func migrations are only based on the filename of the
@@ -284,25 +688,57 @@ func (s *Service) applyFuncMigration(fm FuncMigration) error {
 	// to lib updates, which would otherwise break the hashing contract.
 	checksum, err := s.checksum(bytes.NewReader([]byte(fm.Filename())))
 	if err != nil {
+		s.rollback(tx)
+
 		return fmt.Errorf("failed to create checksum for migration: %w", err)
 	}
 
-	if err := s.insertCompletedMigration(tx, checksum, fm.Filename()); err != nil {
+	if err := s.insertCompletedMigration(ctx, tx, checksum, fm.Filename(), ""); err != nil {
+		s.rollback(tx)
+
 		return fmt.Errorf("failed to insert migration: %w", err)
 	}
 
 	return tx.Commit()
 }
 
-func (s *Service) insertCompletedMigration(tx *sql.Tx, checksum, filename string) error {
-	if _, err := tx.Exec(
-		fmt.Sprintf(
-			`insert into %s (id, checksum) values ('%s', '%s')`,
-			s.migrationTable,
-			filename,
-			checksum,
-		),
-	); err != nil {
+// rollback rolls back tx, logging a warning if that fails. A transaction begun with a context that has since been
+// cancelled is rolled back by database/sql itself, so that is not reported as a failure.
+func (s *Service) rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		s.logger.Warn("rollback failed")
+	}
+}
+
+// execer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// nullString returns v as a bind parameter that is null if v is empty.
+func nullString(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
+
+// recordMigration records a migration as applied, or updates the record of a repeatable migration that was applied
+// again.
+func (s *Service) recordMigration(ctx context.Context, db execer, checksum, filename, variant string,
+	reapply bool,
+) error {
+	if reapply {
+		return s.updateCompletedMigration(ctx, db, checksum, filename, variant)
+	}
+
+	return s.insertCompletedMigration(ctx, db, checksum, filename, variant)
+}
+
+// insertCompletedMigration records a migration as applied. The variant is the dialect of the file that was applied,
+// and is left null if the file was not a variant.
+func (s *Service) insertCompletedMigration(ctx context.Context, db execer, checksum, filename, variant string) error {
+	query := fmt.Sprintf(`insert into %s (id, checksum, variant) values (%s, %s, %s)`,
+		s.quoteTable(s.migrationTable), s.param(1), s.param(2), s.param(3))
+
+	if _, err := db.ExecContext(ctx, query, filename, checksum, nullString(variant)); err != nil {
 		return fmt.Errorf("failed to insert applied migration into migrations table: %w", err)
 	}
 
@@ -315,6 +751,8 @@ func (s *Service) fileHash(filename string) (string, error) {
 		return "", err
 	}
 
+	defer func() { _ = input.Close() }()
+
 	return s.checksum(input)
 }
 
@@ -1,11 +1,16 @@
 package migration
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	code_based "github.com/stimtech/go-migration/v2/test/code-based"
 	code_based_fail "github.com/stimtech/go-migration/v2/test/code-based-fail"
@@ -282,6 +287,156 @@ func TestService_Migrate(t *testing.T) {
 	}
 }
 
+func TestService_MigrateContext(t *testing.T) {
+	migrations := fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table first (id int);")},
+		"migrations/2.go":  {Data: []byte("package migrations")},
+		"migrations/3.sql": {Data: []byte("create table third (id int);")},
+	}
+
+	t.Run("Cancelled context - nothing applied", func(t *testing.T) {
+		db := openTestDB(t)
+		s := newTestService(t, db, migrations)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := s.MigrateContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		tables, err := getTableNames(s, Sqlite)
+		assert.NoError(t, err)
+		assert.Empty(t, tables)
+	})
+
+	t.Run("Cancelled while waiting for lock", func(t *testing.T) {
+		db := openTestDB(t)
+		s := newTestService(t, db, migrations)
+
+		_, err := db.Exec("create table migration_lock (id integer primary key, created_at timestamp)")
+		assert.NoError(t, err)
+		_, err = db.Exec("insert into migration_lock (id, created_at) values (1, current_timestamp)")
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		err = s.MigrateContext(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		// The lock held by someone else must not be released.
+		count := 0
+		assert.NoError(t, db.QueryRow("select count(*) from migration_lock").Scan(&count))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Cancelled in func migration - rolled back", func(t *testing.T) {
+		db := openTestDB(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		s := newTestService(t, db, migrations,
+			FuncMigrationContextOption{Migration: &cancellingMigration{name: "2.go", cancel: cancel}})
+
+		err := s.MigrateContext(ctx)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		tables, err := getTableNames(s, Sqlite)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first", "migration", "migration_lock"}, tables)
+
+		var ids []string
+
+		rows, err := db.Query("select id from migration order by id")
+		if assert.NoError(t, err) {
+			for rows.Next() {
+				var id string
+				assert.NoError(t, rows.Scan(&id))
+				ids = append(ids, id)
+			}
+		}
+
+		assert.Equal(t, []string{"1.sql"}, ids)
+
+		// The lock must be released after a cancelled migration.
+		count := 0
+		assert.NoError(t, db.QueryRow("select count(*) from migration_lock").Scan(&count))
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("Func migration receives context", func(t *testing.T) {
+		db := openTestDB(t)
+		m := &cancellingMigration{name: "2.go"}
+		s := newTestService(t, db, migrations, FuncMigrationContextOption{Migration: m})
+
+		type key struct{}
+
+		ctx := context.WithValue(context.Background(), key{}, "value")
+
+		err := s.MigrateContext(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "value", m.ctx.Value(key{}))
+
+		tables, err := getTableNames(s, Sqlite)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first", "from_func", "migration", "migration_lock", "third"}, tables)
+	})
+}
+
+// cancellingMigration is a FuncMigrationContext that cancels the migration
+// context, if a cancel func is set, after having made a change.
+type cancellingMigration struct {
+	name   string
+	cancel context.CancelFunc
+	ctx    context.Context //nolint:containedctx
+}
+
+func (m *cancellingMigration) ApplyContext(ctx context.Context, tx *sql.Tx) error {
+	m.ctx = ctx
+
+	if _, err := tx.ExecContext(ctx, "create table from_func (id int)"); err != nil {
+		return err
+	}
+
+	if m.cancel != nil {
+		m.cancel()
+	}
+
+	_, err := tx.ExecContext(ctx, "insert into from_func (id) values (1)")
+
+	return err
+}
+
+func (m *cancellingMigration) Filename() string {
+	return m.name
+}
+
+// openTestDB opens a new, empty sqlite database that is removed when the test
+// ends.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open(string(Sqlite), filepath.Join(t.TempDir(), "mig.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+// newTestService returns a Service for db that migrates the "migrations" folder of fsys, and does not log. The opts
+// are applied after these defaults.
+func newTestService(t *testing.T, db *sql.DB, fsys fs.FS, opts ...Option) *Service {
+	t.Helper()
+
+	return New(db, append([]Option{ZapOption{Logger: zap.NewNop()}, Config{MigrationFolder: "migrations"},
+		FSOption{FileSystem: fsys}}, opts...)...)
+}
+
 func getTableNames(s *Service, d SQLDialect) ([]string, error) {
 	var tables []string
 
@@ -340,3 +495,122 @@ func dropTables(s *Service, d SQLDialect) {
 		_, _ = s.db.Exec("drop table if exists " + t)
 	}
 }
+
+func TestService_MigrateTo(t *testing.T) {
+	migrations := fstest.MapFS{
+		"migrations/2024-01-01-a.sql":            {Data: []byte("create table a (id int);")},
+		"migrations/2024-02-01-b.sql":            {Data: []byte("create table b (id int);")},
+		"migrations/2024-03-01-expand-users.sql": {Data: []byte("create table users (id int);")},
+		"migrations/2024-04-01-contract.sql":     {Data: []byte("drop table a;")},
+		"migrations/R__contract_view.sql":        {Data: []byte("create view if not exists v as select id from users;")},
+	}
+
+	db := openTestDB(t)
+	s := newTestService(t, db, migrations)
+
+	err := s.MigrateTo("2024-02-30-missing.sql")
+	assert.ErrorIs(t, err, ErrMigrationNotFound)
+
+	// A repeatable migration is not a target, as it is not applied by MigrateTo.
+	err = s.MigrateTo("R__contract_view.sql")
+	assert.ErrorIs(t, err, ErrMigrationNotFound)
+
+	// Only the first migration, to be able to check that an applied migration
+	// after the target is verified.
+	err = newTestService(t, db, fstest.MapFS{"migrations/2024-02-01-b.sql": migrations["migrations/2024-02-01-b.sql"]}).
+		Migrate()
+	assert.NoError(t, err)
+
+	err = s.MigrateTo("2024-03-01-expand-users.sql")
+	assert.NoError(t, err)
+
+	tables, err := getTableNames(s, Sqlite)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "migration", "migration_lock", "users"}, tables)
+
+	// Repeatable migrations are left for Migrate.
+	var views int
+	err = db.QueryRow("select count(*) from sqlite_master where type = 'view'").Scan(&views)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, views)
+
+	// A changed migration after the target is still verified.
+	changed := fstest.MapFS{}
+	for k, v := range migrations {
+		changed[k] = v
+	}
+
+	changed["migrations/2024-02-01-b.sql"] = &fstest.MapFile{Data: []byte("create table b (id int, name text);")}
+
+	err = newTestService(t, db, changed).MigrateTo("2024-01-01-a.sql")
+
+	var mismatch *ChecksumMismatchError
+	if assert.ErrorAs(t, err, &mismatch) {
+		assert.Equal(t, "2024-02-01-b.sql", mismatch.ID)
+	}
+
+	err = s.Migrate()
+	assert.NoError(t, err)
+
+	tables, err = getTableNames(s, Sqlite)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "migration", "migration_lock", "users"}, tables)
+
+	err = db.QueryRow("select count(*) from sqlite_master where type = 'view'").Scan(&views)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, views)
+}
+
+func TestService_Migrate_QuotesInFilenames(t *testing.T) {
+	db := openTestDB(t)
+	migrations := fstest.MapFS{
+		"migrations/2024-01-01-o'brien.sql":            {Data: []byte("create table users (id int);")},
+		"migrations/2024-01-02-o'brien-fix.sqlite.sql": {Data: []byte("create table roles (id int);")},
+		"migrations/2024-01-03-'); drop table users;--.sql": {
+			Data: []byte("create table groups (id int);"),
+		},
+		"migrations/R__o'brien.sql": {Data: []byte("create view if not exists user_ids as select id from users;")},
+	}
+
+	s := newTestService(t, db, migrations, Config{DialectVariants: true})
+
+	// The first migration is baselined, as its table was created by hand.
+	if _, err := db.Exec("create table users (id int);"); !assert.NoError(t, err) {
+		return
+	}
+
+	if !assert.NoError(t, s.Baseline("2024-01-01-o'brien.sql")) {
+		return
+	}
+
+	res, err := s.MigrateWithResult(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"2024-01-02-o'brien-fix.sql", "2024-01-03-'); drop table users;--.sql", "R__o'brien.sql"},
+		res.Applied())
+
+	// The repeatable migration is updated when it changes.
+	migrations["migrations/R__o'brien.sql"] = &fstest.MapFile{
+		Data: []byte("drop view if exists user_ids; create view user_ids as select id from users;"),
+	}
+
+	res, err = s.MigrateWithResult(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"R__o'brien.sql"}, res.Applied())
+	}
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 4) {
+		for _, st := range statuses {
+			assert.Equal(t, StateApplied, st.State, st.ID)
+		}
+
+		assert.True(t, statuses[0].Baselined)
+		assert.Equal(t, "sqlite", statuses[1].AppliedVariant)
+	}
+
+	count := 0
+	assert.NoError(t, db.QueryRow("select count(*) from users").Scan(&count), "the users table was not dropped")
+}
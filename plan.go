@@ -0,0 +1,133 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MigrationKind tells how a migration is applied.
+type MigrationKind string
+
+const (
+	// KindSQL is a migration written as a SQL file.
+	KindSQL MigrationKind = "sql"
+
+	// KindFunc is a code based migration, see FuncMigration.
+	KindFunc MigrationKind = "func"
+)
+
+// PlannedMigration is a migration that Migrate would apply.
+type PlannedMigration struct {
+	// ID is the unique ID of the migration, which is also its filename.
+	ID string
+
+	// Variant is the dialect of the variant that will be applied, or empty if the file is not a variant.
+	Variant string
+
+	// Kind tells if the migration is a SQL file or a func migration. It is empty for other files in the migration
+	// folder, which are skipped.
+	Kind MigrationKind
+
+	// Repeatable is set for repeatable migrations, which are applied again whenever they change.
+	Repeatable bool
+
+	// Checksum is the checksum that will be stored for the migration once applied.
+	// It is empty for migrations that will be skipped.
+	Checksum string
+
+	// Statements are the statements of a SQL migration, in the order they will be executed.
+	Statements []string
+
+	// NoTransaction is set for SQL migrations with the no-transaction directive, whose statements are not executed
+	// in a transaction.
+	NoTransaction bool
+
+	// Skip is set for files in the migration folder that Migrate will not apply, such as
+	// .go files without a matching FuncMigration.
+	Skip bool
+}
+
+// Plan returns the migrations that Migrate would apply, in the order they would be applied, without applying them.
+// Repeatable migrations are included if they have not been applied yet, or have changed since they were applied.
+// Plan does not take the lock and does not create the migration tables, so the result may be outdated if another
+// instance is migrating the database at the same time.
+func (s *Service) Plan() ([]PlannedMigration, error) {
+	return s.plan(context.Background())
+}
+
+func (s *Service) plan(ctx context.Context) ([]PlannedMigration, error) {
+	appliedMigs, err := s.fetchAppliedMigrationsIfExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.listMigrations(appliedMigs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list available migrations: %w", err)
+	}
+
+	var plan []PlannedMigration
+
+	for _, mig := range s.orderedIDs(files) {
+		file := files[mig]
+		repeatable := s.isRepeatable(mig)
+
+		if appliedMig, applied := appliedMigs[mig]; applied {
+			if !repeatable {
+				continue
+			}
+
+			// Repeatable migrations are only applied again if they have changed.
+			c, err := s.migrationChecksum(file)
+			if err != nil {
+				return nil, err
+			}
+
+			if c == appliedMig.Checksum {
+				continue
+			}
+		}
+
+		p := PlannedMigration{ID: mig, Repeatable: repeatable}
+		p.Variant = s.variantOf(mig, file)
+
+		funcMigration, err := s.shouldApplyFuncMigration(mig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine if func migration should be applied: %w", err)
+		}
+
+		switch {
+		case funcMigration != nil:
+			p.Kind = KindFunc
+		case strings.HasSuffix(mig, ".go"):
+			p.Kind = KindFunc
+			p.Skip = true
+		case !isSQLMigration(mig):
+			p.Skip = true
+		default:
+			p.Kind = KindSQL
+
+			sqlMig, err := s.readSQLMigration(mig, file)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, stmt := range sqlMig.statements {
+				p.Statements = append(p.Statements, stmt.SQL)
+			}
+			p.NoTransaction = sqlMig.noTransaction
+		}
+
+		if !p.Skip {
+			p.Checksum, err = s.migrationChecksum(file)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		plan = append(plan, p)
+	}
+
+	return plan, nil
+}
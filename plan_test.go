@@ -0,0 +1,75 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	code_based "github.com/stimtech/go-migration/v2/test/code-based"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Plan(t *testing.T) {
+	migrations := fstest.MapFS{
+		"migrations/1.sql":       {Data: []byte("create table first (id int);\n\ninsert into first values (1);\n")},
+		"migrations/2.go":        {Data: []byte("package migrations")},
+		"migrations/3.go":        {Data: []byte("package migrations")},
+		"migrations/4.sql":       {Data: []byte("create table fourth (id int);")},
+		"migrations/README.md":   {Data: []byte("# Migrations")},
+		"migrations/sub/ignored": {Data: []byte("not a migration")},
+	}
+
+	t.Run("Nothing applied", func(t *testing.T) {
+		db := openTestDB(t)
+		s := newTestService(t, db, migrations, FuncMigrationOption{Migration: &code_based.CBTest2{Name: "2.go"}})
+
+		plan, err := s.Plan()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, []PlannedMigration{
+			{
+				ID:         "1.sql",
+				Kind:       KindSQL,
+				Checksum:   "f5d19b1e2fba2791692a6c32f890e9ec",
+				Statements: []string{"create table first (id int)", "\n\ninsert into first values (1)"},
+			},
+			{ID: "2.go", Kind: KindFunc, Checksum: "684c11abf5aad4cb89bf43235235b1a9"},
+			{ID: "3.go", Kind: KindFunc, Skip: true},
+			{ID: "4.sql", Kind: KindSQL, Checksum: "022b5a81cd406a56483c65bcc9bd51c9",
+				Statements: []string{"create table fourth (id int)"}},
+			{ID: "README.md", Skip: true},
+		}, plan)
+
+		// Plan must not create the migration tables.
+		tables, err := getTableNames(s, Sqlite)
+		assert.NoError(t, err)
+		assert.Empty(t, tables)
+	})
+
+	t.Run("Partially applied", func(t *testing.T) {
+		db := openTestDB(t)
+		applied := fstest.MapFS{"migrations/1.sql": migrations["migrations/1.sql"]}
+
+		err := newTestService(t, db, applied).Migrate()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		s := newTestService(t, db, migrations)
+
+		plan, err := s.Plan()
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		var ids []string
+		for _, p := range plan {
+			ids = append(ids, p.ID)
+		}
+
+		assert.Equal(t, []string{"2.go", "3.go", "4.sql", "README.md"}, ids)
+		assert.True(t, plan[0].Skip, "2.go has no func migration")
+	})
+}
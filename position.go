@@ -0,0 +1,168 @@
+package migration
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxExcerptLength is the maximum number of characters of a failing statement shown in errors.
+const maxExcerptLength = 60
+
+// newStatementError returns the error for a failing statement in sqlMig, the SQL migration applied for mig. The error
+// points at the start of the statement, or at the position reported by the database if there is one.
+func newStatementError(mig string, sqlMig sqlMigration, index int, stmt Statement, err error) *StatementError {
+	src := sqlMig.src
+	offset := stmt.Offset + leadingSpaceAndComments(stmt.SQL)
+
+	if pos, ok := errorPosition(err); ok && pos <= utf8.RuneCountInString(stmt.SQL) {
+		offset = stmt.Offset + runeOffset(stmt.SQL, pos-1)
+	}
+
+	line, column := position(src, offset)
+
+	return &StatementError{
+		ID:       mig,
+		File:     sqlMig.file,
+		Rendered: sqlMig.rendered,
+		Index:    index + 1,
+		SQL:      stmt.SQL,
+		Err:      err,
+		Line:     line,
+		Column:   column,
+		Excerpt:  excerpt(src, offset),
+	}
+}
+
+// errorPosition returns the 1-based character position in the statement reported by the database in err, if any.
+// PostgreSQL reports it, and the drivers put it in a Position field of their error: an integer in PgError of pgx v4
+// and v5, and a string in Error of lib/pq. The field is found by reflection, so that no driver has to be imported.
+func errorPosition(err error) (int, bool) {
+	for err != nil {
+		if pos, ok := positionField(err); ok {
+			return pos, true
+		}
+
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				if pos, ok := errorPosition(e); ok {
+					return pos, true
+				}
+			}
+
+			return 0, false
+		}
+
+		err = errors.Unwrap(err)
+	}
+
+	return 0, false
+}
+
+// positionField returns the value of the Position field of err, if it is a struct, or a pointer to one, with a
+// positive integer or numeric string Position field.
+func positionField(err error) (int, bool) {
+	v := reflect.ValueOf(err)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return 0, false
+		}
+
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+
+	f := v.FieldByName("Position")
+	if !f.IsValid() {
+		return 0, false
+	}
+
+	pos := 0
+
+	switch f.Kind() { //nolint:exhaustive
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		pos = int(f.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		pos = int(f.Uint()) //nolint:gosec
+	case reflect.String:
+		pos, _ = strconv.Atoi(f.String())
+	default:
+		return 0, false
+	}
+
+	return pos, pos > 0
+}
+
+// position returns the 1-based line and column of offset in src. Columns count characters, not bytes.
+func position(src string, offset int) (int, int) {
+	lineStart := strings.LastIndexByte(src[:offset], '\n') + 1
+
+	return strings.Count(src[:offset], "\n") + 1, utf8.RuneCountInString(src[lineStart:offset]) + 1
+}
+
+// excerpt returns the line of src holding offset, shortened to at most maxExcerptLength characters.
+func excerpt(src string, offset int) string {
+	start := strings.LastIndexByte(src[:offset], '\n') + 1
+
+	end := strings.IndexByte(src[offset:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += offset
+	}
+
+	line := strings.TrimSpace(src[start:end])
+	if utf8.RuneCountInString(line) > maxExcerptLength {
+		line = line[:runeOffset(line, maxExcerptLength)] + "..."
+	}
+
+	return line
+}
+
+// runeOffset returns the byte offset of the n:th character in s.
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+
+		n--
+	}
+
+	return len(s)
+}
+
+// leadingSpaceAndComments returns the length of the whitespace and comments at the start of a statement.
+func leadingSpaceAndComments(sql string) int {
+	i := 0
+
+	for i < len(sql) {
+		switch {
+		case isSpace(sql[i]):
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				return len(sql)
+			}
+
+			i += end + 1
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return len(sql)
+			}
+
+			i += end + 4
+		default:
+			return i
+		}
+	}
+
+	return i
+}
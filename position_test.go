@@ -0,0 +1,111 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// pgError has the Position field of PgError in pgx.
+type pgError struct {
+	Message  string
+	Position int32
+}
+
+func (e *pgError) Error() string {
+	return e.Message
+}
+
+// pqError has the Position field of Error in lib/pq.
+type pqError struct {
+	Message  string
+	Position string
+}
+
+func (e pqError) Error() string {
+	return e.Message
+}
+
+func Test_newStatementError(t *testing.T) {
+	src := "-- migration:no-transaction\n" +
+		"create table a (id int);\n" +
+		"\n" +
+		"/* The second statement. */\n" +
+		"  insert into a\n" +
+		"    (id) valuez (1);\n" +
+		"insert into a (id) values ('åäö'), ('this line is long enough to be shortened in the error message');\n"
+
+	stmts, err := GenericDialect{}.SplitStatements(src)
+	if !assert.NoError(t, err) || !assert.Len(t, stmts, 3) {
+		return
+	}
+
+	tests := []struct {
+		name  string
+		index int
+		err   error
+		want  *StatementError
+	}{
+		{
+			name:  "first statement after header",
+			index: 0,
+			err:   errors.New("boom"),
+			want:  &StatementError{Line: 2, Column: 1, Excerpt: "create table a (id int);"},
+		},
+		{
+			name:  "statement after comment",
+			index: 1,
+			err:   errors.New("boom"),
+			want:  &StatementError{Line: 5, Column: 3, Excerpt: "insert into a"},
+		},
+		{
+			name:  "position reported by postgres",
+			index: 1,
+			// Position 56 is the v in valuez, counting from the newline before the comment.
+			err:  fmt.Errorf("wrapped: %w", &pgError{Message: "syntax error", Position: 56}),
+			want: &StatementError{Line: 6, Column: 10, Excerpt: "(id) valuez (1);"},
+		},
+		{
+			name:  "position counts characters, not bytes",
+			index: 2,
+			// Position 33 is the quote after åäö, counting from the newline before the statement.
+			err: &pgError{Message: "syntax error", Position: 33},
+			want: &StatementError{Line: 7, Column: 32,
+				Excerpt: "insert into a (id) values ('åäö'), ('this line is long enoug..."},
+		},
+		{
+			name:  "position reported by lib/pq",
+			index: 1,
+			err:   errors.Join(errors.New("rollback failed"), pqError{Message: "syntax error", Position: "56"}),
+			want:  &StatementError{Line: 6, Column: 10, Excerpt: "(id) valuez (1);"},
+		},
+		{
+			name:  "position outside statement",
+			index: 0,
+			err:   &pgError{Message: "syntax error", Position: 1000},
+			want:  &StatementError{Line: 2, Column: 1, Excerpt: "create table a (id int);"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmt := stmts[tt.index]
+			got := newStatementError("1.sql", sqlMigration{file: "1.sql", src: src}, tt.index, stmt, tt.err)
+
+			tt.want.ID = "1.sql"
+			tt.want.File = "1.sql"
+			tt.want.Index = tt.index + 1
+			tt.want.SQL = stmt.SQL
+			tt.want.Err = tt.err
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStatementError_Error(t *testing.T) {
+	err := &StatementError{ID: "2024-05-01-foo.sql", Index: 3, Line: 123, Column: 5, Excerpt: "select x",
+		Err: errors.New("no such column: x")}
+	assert.EqualError(t, err, "2024-05-01-foo.sql:123:5: failing statement 3 [select x]: no such column: x")
+}
@@ -0,0 +1,72 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// isRepeatable reports whether a migration is repeatable. Repeatable migrations are SQL migrations whose filename
+// starts with the repeatable prefix, R__ by default. They are applied after all other migrations, and applied again
+// whenever they change.
+func (s *Service) isRepeatable(mig string) bool {
+	return strings.HasPrefix(mig, s.repeatablePrefix) && isSQLMigration(mig)
+}
+
+// orderedIDs returns the IDs of the migrations listed by listMigrations, in the order they are applied: other
+// migrations in alphabetical order, followed by repeatable migrations in alphabetical order.
+func (s *Service) orderedIDs(files map[string]string) []string {
+	ids := make([]string, 0, len(files))
+	for id := range files {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		if ri, rj := s.isRepeatable(ids[i]), s.isRepeatable(ids[j]); ri != rj {
+			return rj
+		}
+
+		return ids[i] < ids[j]
+	})
+
+	return ids
+}
+
+// migrateRepeatable applies a repeatable migration from file if it has not been applied yet, or if it has changed
+// since it was last applied.
+func (s *Service) migrateRepeatable(ctx context.Context, mig, file string, appliedMigs map[string]migration) (
+	Action, error,
+) {
+	c, err := s.migrationChecksum(file)
+	if err != nil {
+		return ActionFailed, err
+	}
+
+	appliedMig, applied := appliedMigs[mig]
+	if applied && appliedMig.Checksum == c {
+		return ActionVerified, nil
+	}
+
+	if applied {
+		s.logger.Info(fmt.Sprintf("repeatable migration %s has changed since it was applied", mig))
+	}
+
+	if err := s.applySQLMigration(ctx, mig, file, applied); err != nil {
+		return ActionFailed, fmt.Errorf("failed to apply repeatable migration %s: %w", mig, err)
+	}
+
+	return ActionApplied, nil
+}
+
+// updateCompletedMigration updates the record of a repeatable migration that has been applied again.
+func (s *Service) updateCompletedMigration(ctx context.Context, db execer, checksum, filename, variant string) error {
+	query := fmt.Sprintf(`update %s set checksum = %s, date = current_timestamp, variant = %s where id = %s`,
+		s.quoteTable(s.migrationTable), s.param(1), s.param(2), s.param(3))
+
+	if _, err := db.ExecContext(ctx, query, checksum, nullString(variant), filename); err != nil {
+		return fmt.Errorf("failed to update applied migration in migrations table: %w", err)
+	}
+
+	return nil
+}
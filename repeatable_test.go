@@ -0,0 +1,84 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Migrate_Repeatable(t *testing.T) {
+	db := openTestDB(t)
+	migrations := fstest.MapFS{
+		"migrations/users.sql": {Data: []byte("create table users (id int, name text);")},
+		"migrations/R__users_view.sql": {
+			Data: []byte("drop view if exists user_ids; create view user_ids as select id from users;"),
+		},
+	}
+
+	s := newTestService(t, db, migrations)
+
+	// Repeatable migrations are applied after the other migrations, even though R__ sorts first.
+	res, err := s.MigrateWithResult(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"users.sql", "R__users_view.sql"}, res.Applied())
+	}
+
+	// Unchanged repeatable migrations are not applied again.
+	res, err = s.MigrateWithResult(context.Background())
+	if assert.NoError(t, err) && assert.Len(t, res.Migrations, 2) {
+		assert.Equal(t, ActionVerified, res.Migrations[1].Action)
+	}
+
+	migrations["migrations/R__users_view.sql"] = &fstest.MapFile{
+		Data: []byte("drop view if exists user_ids; create view user_ids as select id, name from users;"),
+	}
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 2) {
+		assert.Equal(t, "R__users_view.sql", statuses[0].ID)
+		assert.Equal(t, StatePending, statuses[0].State)
+		assert.True(t, statuses[0].Repeatable)
+	}
+
+	var verr *ValidationError
+	if assert.ErrorAs(t, s.Validate(), &verr) {
+		assert.Empty(t, verr.Mismatches)
+		assert.Equal(t, []string{"R__users_view.sql"}, verr.Pending)
+	}
+
+	plan, err := s.Plan()
+	if assert.NoError(t, err) && assert.Len(t, plan, 1) {
+		assert.Equal(t, "R__users_view.sql", plan[0].ID)
+		assert.True(t, plan[0].Repeatable)
+	}
+
+	// A changed repeatable migration is applied again, and its checksum is updated instead of failing.
+	res, err = s.MigrateWithResult(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"R__users_view.sql"}, res.Applied())
+	}
+
+	_, err = db.Exec("select name from user_ids")
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Validate())
+
+	count := 0
+	assert.NoError(t, db.QueryRow("select count(*) from migration").Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+func TestService_Migrate_RepeatablePrefix(t *testing.T) {
+	s := newTestService(t, openTestDB(t), fstest.MapFS{
+		"migrations/R__1.sql":         {Data: []byte("create table first (id int);")},
+		"migrations/2.sql":            {Data: []byte("create table second (id int);")},
+		"migrations/repeatable-0.sql": {Data: []byte("create view v as select id from first;")},
+	}, Config{RepeatablePrefix: "repeatable-"})
+
+	res, err := s.MigrateWithResult(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"2.sql", "R__1.sql", "repeatable-0.sql"}, res.Applied())
+	}
+}
@@ -0,0 +1,67 @@
+package migration
+
+import (
+	"time"
+)
+
+// Action is what Migrate did with a migration.
+type Action string
+
+const (
+	// ActionApplied is a migration that was applied.
+	ActionApplied Action = "applied"
+
+	// ActionVerified is a migration that had already been applied, and whose checksum was verified.
+	ActionVerified Action = "verified"
+
+	// ActionSkipped is a file in the migration folder that is not a migration, such as a .go file without a matching
+	// FuncMigration.
+	ActionSkipped Action = "skipped"
+
+	// ActionFailed is a migration that could not be applied or verified. It is always the last migration in a
+	// MigrateResult.
+	ActionFailed Action = "failed"
+)
+
+// MigrationResult is what Migrate did with a single migration.
+type MigrationResult struct {
+	// ID is the unique ID of the migration, which is also its filename.
+	ID string
+
+	// Variant is the dialect of the variant that was applied or verified, or empty if the file is not a variant.
+	Variant string
+
+	// Action is what was done with the migration.
+	Action Action
+
+	// Duration is the time it took to apply or verify the migration.
+	Duration time.Duration
+}
+
+// MigrateResult is the result of a migration, as returned by MigrateWithResult.
+type MigrateResult struct {
+	// Migrations are the migrations handled, in the order they were handled.
+	Migrations []MigrationResult
+
+	// LockWait is the time spent waiting for the migration lock.
+	LockWait time.Duration
+
+	// LockAttempts is the number of attempts made to take the migration lock.
+	LockAttempts int
+
+	// Duration is the total time of the migration, including LockWait.
+	Duration time.Duration
+}
+
+// Applied returns the IDs of the migrations that were applied.
+func (r *MigrateResult) Applied() []string {
+	var ids []string
+
+	for _, m := range r.Migrations {
+		if m.Action == ActionApplied {
+			ids = append(ids, m.ID)
+		}
+	}
+
+	return ids
+}
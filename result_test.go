@@ -0,0 +1,52 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_MigrateWithResult(t *testing.T) {
+	db := openTestDB(t)
+
+	first := fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table first (id int);")},
+	}
+
+	res, err := newTestService(t, db, first).MigrateWithResult(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"1.sql"}, res.Applied())
+
+	second := fstest.MapFS{
+		"migrations/1.sql":  first["migrations/1.sql"],
+		"migrations/2.go":   {Data: []byte("package migrations")},
+		"migrations/3.sql":  {Data: []byte("create table third (id int);")},
+		"migrations/4.sql":  {Data: []byte("create table fourth (id int); not sql;")},
+		"migrations/5.sql":  {Data: []byte("create table fifth (id int);")},
+		"migrations/README": {Data: []byte("not a migration")},
+	}
+
+	res, err = newTestService(t, db, second).MigrateWithResult(context.Background())
+	assert.Error(t, err)
+
+	if !assert.NotNil(t, res) {
+		return
+	}
+
+	var actions []Action
+
+	for _, m := range res.Migrations {
+		actions = append(actions, m.Action)
+		assert.Positive(t, m.Duration, m.ID)
+	}
+
+	assert.Equal(t, []Action{ActionVerified, ActionSkipped, ActionApplied, ActionFailed}, actions)
+	assert.Equal(t, "4.sql", res.Migrations[3].ID)
+	assert.Equal(t, []string{"3.sql"}, res.Applied())
+	assert.GreaterOrEqual(t, res.Duration, res.LockWait)
+}
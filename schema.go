@@ -0,0 +1,137 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// identifierPattern matches the table and schema names that go-migration accepts. The length is limited to that of
+// PostgreSQL, which silently truncates longer names.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]{0,62}$`)
+
+// SchemaCreator is implemented by dialects that can create a schema, which is needed for Config.CreateSchema.
+type SchemaCreator interface {
+	// CreateSchema returns a statement that creates a schema, unless it already exists.
+	CreateSchema(schema string) string
+}
+
+// CreateSchema returns a create schema if not exists statement.
+func (d GenericDialect) CreateSchema(schema string) string {
+	return "create schema if not exists " + d.QuoteIdentifier(schema)
+}
+
+// CreateSchema returns a create schema if not exists statement.
+func (d PostgresDialect) CreateSchema(schema string) string {
+	return "create schema if not exists " + d.QuoteIdentifier(schema)
+}
+
+// CreateSchema returns a create schema if not exists statement, which creates a database.
+func (d MySQLDialect) CreateSchema(schema string) string {
+	return "create schema if not exists " + d.QuoteIdentifier(schema)
+}
+
+// CreateSchema returns a statement that creates the schema if schema_id does not find it. Create schema must be the
+// only statement of its batch, so it is run with exec.
+func (d SQLServerDialect) CreateSchema(schema string) string {
+	return fmt.Sprintf("if schema_id(N'%s') is null exec(N'create schema %s')",
+		strings.ReplaceAll(schema, "'", "''"), strings.ReplaceAll(d.QuoteIdentifier(schema), "'", "''"))
+}
+
+// splitTable returns the schema and the name of a table. A table name qualified with a schema, like ops.migration,
+// is split, otherwise the schema is Config.Schema, which is empty for the current schema.
+func (s *Service) splitTable(name string) (string, string) {
+	if schema, table, ok := strings.Cut(name, "."); ok {
+		return schema, table
+	}
+
+	return s.schema, name
+}
+
+// validateNames checks that the names of the migration and lock tables, and their schemas, are plain identifiers.
+func (s *Service) validateNames() error {
+	for _, name := range []string{s.migrationTable, s.migrationLockTable} {
+		if s.schema != "" && strings.Contains(name, ".") {
+			return fmt.Errorf("%w: table %q has a schema, which must not be combined with Config.Schema",
+				ErrInvalidName, name)
+		}
+
+		schema, table := s.splitTable(name)
+
+		if schema != "" && !identifierPattern.MatchString(schema) {
+			return fmt.Errorf("%w: schema %q", ErrInvalidName, schema)
+		}
+
+		if !identifierPattern.MatchString(table) {
+			return fmt.Errorf("%w: table %q", ErrInvalidName, table)
+		}
+	}
+
+	return nil
+}
+
+// checkLowerCaseTables returns ErrLowerCaseTable if the migration or lock table only exists with a lower-case name.
+// PostgreSQL folds unquoted names to lower case, so versions that did not quote the names created e.g. Migrations as
+// migrations. Creating the quoted name instead would apply all migrations again.
+func (s *Service) checkLowerCaseTables(ctx context.Context) error {
+	if s.currentDialect().Name() != (PostgresDialect{}).Name() {
+		return nil
+	}
+
+	for _, name := range []string{s.migrationTable, s.migrationLockTable} {
+		if s.schema != "" {
+			name = s.schema + "." + name
+		}
+
+		lower := strings.ToLower(name)
+		if lower == name {
+			continue
+		}
+
+		exists, err := s.tableExists(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to check for table %s: %w", name, err)
+		}
+
+		if exists {
+			continue
+		}
+
+		if exists, err = s.tableExists(ctx, lower); err != nil {
+			return fmt.Errorf("failed to check for table %s: %w", lower, err)
+		}
+
+		if exists {
+			return fmt.Errorf("%w: found %s, but not %s; rename the table to %s or configure the name in lower case",
+				ErrLowerCaseTable, lower, name, s.quoteTable(name))
+		}
+	}
+
+	return nil
+}
+
+// createSchemas creates the schemas of the migration and lock tables, unless they exist.
+func (s *Service) createSchemas(ctx context.Context) error {
+	creator, ok := s.currentDialect().(SchemaCreator)
+	if !ok {
+		return fmt.Errorf("the %s dialect cannot create schemas", s.currentDialect().Name())
+	}
+
+	created := map[string]bool{}
+
+	for _, name := range []string{s.migrationTable, s.migrationLockTable} {
+		schema, _ := s.splitTable(name)
+		if schema == "" || created[schema] {
+			continue
+		}
+
+		if _, err := s.db.ExecContext(ctx, creator.CreateSchema(schema)); err != nil {
+			return fmt.Errorf("failed to create schema %s: %w", schema, err)
+		}
+
+		created[schema] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_validateNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "defaults"},
+		{name: "schema", config: Config{Schema: "ops", TableName: "schema_migrations", LockTableName: "lock$1"}},
+		{name: "schema in table name", config: Config{TableName: "ops.migration"}},
+		{
+			name: "schema in table name and config", config: Config{Schema: "ops", TableName: "ops.migration"},
+			wantErr: `invalid table or schema name: table "ops.migration" has a schema, which must not be combined ` +
+				`with Config.Schema`,
+		},
+		{
+			name: "injected table name", config: Config{TableName: "migration; drop table users"},
+			wantErr: `invalid table or schema name: table "migration; drop table users"`,
+		},
+		{
+			name: "quote in schema", config: Config{Schema: `ops"`},
+			wantErr: `invalid table or schema name: schema "ops\""`,
+		},
+		{
+			name: "leading digit", config: Config{LockTableName: "1lock"},
+			wantErr: `invalid table or schema name: table "1lock"`,
+		},
+		{
+			name: "too long", config: Config{TableName: strings.Repeat("m", 64)},
+			wantErr: `invalid table or schema name: table "` + strings.Repeat("m", 64) + `"`,
+		},
+		{name: "longest", config: Config{TableName: strings.Repeat("m", 63)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(nil, tt.config).validateNames()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			assert.True(t, errors.Is(err, ErrInvalidName))
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSchemaCreator(t *testing.T) {
+	tests := []struct {
+		dialect SchemaCreator
+		want    string
+	}{
+		{dialect: GenericDialect{}, want: "create schema if not exists ops"},
+		{dialect: PostgresDialect{}, want: `create schema if not exists "ops"`},
+		{dialect: MySQLDialect{}, want: "create schema if not exists `ops`"},
+		{dialect: SQLServerDialect{}, want: "if schema_id(N'ops') is null exec(N'create schema [ops]')"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.(Dialect).Name(), func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.dialect.CreateSchema("ops"))
+		})
+	}
+}
+
+func TestService_Migrate_Schema(t *testing.T) {
+	db := openTestDB(t)
+
+	// An attached database is a schema in SQLite. It is attached to a connection, so only one is used.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("attach database ? as ops", filepath.Join(t.TempDir(), "ops.db")); !assert.NoError(t, err) {
+		return
+	}
+
+	s := newTestService(t, db, fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table users (id int);")},
+	}, Config{Schema: "ops"})
+
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	count := 0
+	assert.NoError(t, db.QueryRow("select count(*) from ops.migration").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	for _, table := range []string{"migration", "migration_lock"} {
+		assert.NoError(t, db.QueryRow("select count(*) from main.sqlite_master where name = ?", table).Scan(&count))
+		assert.Equal(t, 0, count, "%s is not created in the main schema", table)
+	}
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 1) {
+		assert.Equal(t, StateApplied, statuses[0].State)
+	}
+
+	info, err := s.LockStatus()
+	assert.NoError(t, err)
+	assert.False(t, info.Held)
+}
+
+func TestService_Migrate_CreateSchema(t *testing.T) {
+	s := newTestService(t, openTestDB(t), fstest.MapFS{}, Config{Schema: "ops", CreateSchema: true})
+
+	assert.EqualError(t, s.MigrateContext(context.Background()),
+		"failed to create migration tables: the sqlite dialect cannot create schemas")
+}
+
+func TestService_Migrate_InvalidName(t *testing.T) {
+	s := newTestService(t, openTestDB(t), fstest.MapFS{}, Config{TableName: "migration(id int); drop table users; --"})
+
+	assert.ErrorIs(t, s.Migrate(), ErrInvalidName)
+
+	_, err := s.Status()
+	assert.ErrorIs(t, err, ErrInvalidName)
+}
+
+// postgresNamedDialect is the SQLite dialect, named like the PostgreSQL dialect.
+type postgresNamedDialect struct {
+	SQLiteDialect
+}
+
+func (postgresNamedDialect) Name() string {
+	return PostgresDialect{}.Name()
+}
+
+func TestService_Migrate_LowerCaseTable(t *testing.T) {
+	db := openTestDB(t)
+	migrations := fstest.MapFS{"migrations/1.sql": {Data: []byte("create table users (id int);")}}
+
+	// Created by a version that did not quote the names, so PostgreSQL folded Migrations to lower case.
+	if !assert.NoError(t, newTestService(t, db, migrations, Config{TableName: "migrations"}).Migrate()) {
+		return
+	}
+
+	s := newTestService(t, db, migrations, Config{TableName: "Migrations"},
+		DialectOption{Dialect: postgresNamedDialect{}})
+
+	err := s.Migrate()
+	assert.ErrorIs(t, err, ErrLowerCaseTable)
+	assert.ErrorContains(t, err, `found migrations, but not Migrations; rename the table to "Migrations"`)
+
+	// Other databases do not fold the names.
+	s = newTestService(t, db, migrations, Config{TableName: "Migrations"}, DialectOption{Dialect: SQLiteDialect{}})
+	assert.NoError(t, s.Migrate())
+}
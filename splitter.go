@@ -0,0 +1,443 @@
+package migration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// splitRules are the lexical rules used when splitting SQL into statements. They differ between dialects.
+type splitRules struct {
+	// backslashEscapes makes a backslash escape the next character in quoted strings, as in MySQL.
+	backslashEscapes bool
+
+	// escapeStrings enables E'...' strings, in which a backslash escapes the next character, as in PostgreSQL.
+	escapeStrings bool
+
+	// dollarQuotes enables $tag$...$tag$ quoted strings, as in PostgreSQL.
+	dollarQuotes bool
+
+	// backticks enables `quoted` identifiers.
+	backticks bool
+
+	// brackets enables [quoted] identifiers.
+	brackets bool
+
+	// hashComments makes # start a comment that runs to the end of the line, as in MySQL.
+	hashComments bool
+
+	// dashCommentNeedsSpace requires -- to be followed by whitespace to start a comment, as in MySQL.
+	dashCommentNeedsSpace bool
+
+	// nestedComments makes /* */ comments nest, as in PostgreSQL.
+	nestedComments bool
+
+	// executableComments makes /*! */ comments count as SQL, as in MySQL.
+	executableComments bool
+
+	// triggerBlocks keeps the BEGIN ... END body of a CREATE TRIGGER statement together, as in SQLite.
+	triggerBlocks bool
+
+	// atomicBlocks keeps BEGIN ATOMIC ... END bodies together, as in PostgreSQL.
+	atomicBlocks bool
+
+	// delimiterDirective enables DELIMITER lines that change the statement terminator, as in the mysql client.
+	delimiterDirective bool
+
+	// goBatches splits on GO lines instead of semicolons, as in sqlcmd and SQL Server Management Studio. The
+	// statements are then batches, which may contain several statements.
+	goBatches bool
+}
+
+// splitSQL splits src into statements, on semicolons that are not inside quotes, comments or blocks. The statements
+// are returned as written, together with their offset in src, except for statements consisting only of whitespace and
+// comments, which are left out. DELIMITER directives and GO lines, if enabled, are removed.
+func splitSQL(src string, rules splitRules) ([]Statement, error) {
+	sp := &splitter{src: src, rules: rules, delimiter: ";"}
+
+	if err := sp.split(); err != nil {
+		return nil, err
+	}
+
+	return sp.statements, nil
+}
+
+type splitter struct {
+	src        string
+	rules      splitRules
+	pos        int
+	statements []Statement
+
+	delimiter string   // the statement terminator, changed by DELIMITER directives
+	start     int      // offset of the current statement
+	content   bool     // whether the current statement has anything but whitespace and comments
+	words     []string // the first words of the current statement, upper cased
+	depth     int      // BEGIN ... END nesting depth
+}
+
+// maxLeadingWords is the number of words at the start of a statement that are kept to tell what kind of statement it
+// is. It fits e.g. "CREATE TEMPORARY TRIGGER IF NOT EXISTS".
+const maxLeadingWords = 6
+
+func (sp *splitter) split() error {
+	for sp.pos < len(sp.src) {
+		c := sp.src[sp.pos]
+
+		var err error
+
+		switch {
+		case sp.depth == 0 && !sp.rules.goBatches && strings.HasPrefix(sp.src[sp.pos:], sp.delimiter):
+			sp.emit(sp.pos)
+			sp.pos += len(sp.delimiter)
+			sp.start = sp.pos
+		case c == '\'':
+			err = sp.skipQuoted('\'', '\'', sp.rules.backslashEscapes || sp.isEscapeString())
+		case c == '"':
+			err = sp.skipQuoted('"', '"', sp.rules.backslashEscapes)
+		case c == '`' && sp.rules.backticks:
+			err = sp.skipQuoted('`', '`', false)
+		case c == '[' && sp.rules.brackets:
+			err = sp.skipQuoted('[', ']', false)
+		case c == '-' && sp.isDashComment():
+			sp.skipLine()
+		case c == '#' && sp.rules.hashComments:
+			sp.skipLine()
+		case c == '/' && sp.peek(1) == '*':
+			err = sp.skipBlockComment()
+		case c == '$' && sp.rules.dollarQuotes:
+			err = sp.skipDollarQuoted()
+		case isIdentStart(c):
+			err = sp.word()
+		case isSpace(c):
+			sp.pos++
+		default:
+			sp.content = true
+			sp.pos++
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	sp.emit(len(sp.src))
+
+	return nil
+}
+
+// emit ends the current statement at end.
+func (sp *splitter) emit(end int) {
+	if sp.content {
+		sp.statements = append(sp.statements, Statement{SQL: sp.src[sp.start:end], Offset: sp.start})
+	}
+
+	sp.content = false
+	sp.words = sp.words[:0]
+	sp.depth = 0
+}
+
+func (sp *splitter) peek(n int) byte {
+	if sp.pos+n >= len(sp.src) {
+		return 0
+	}
+
+	return sp.src[sp.pos+n]
+}
+
+// line returns the line number of offset, for error messages.
+func (sp *splitter) line(offset int) int {
+	return strings.Count(sp.src[:offset], "\n") + 1
+}
+
+// skipQuoted skips a quoted string or identifier. A doubled closing quote is part of the string.
+func (sp *splitter) skipQuoted(open, closing byte, backslashEscapes bool) error {
+	start := sp.pos
+	sp.content = true
+	sp.pos++
+
+	for sp.pos < len(sp.src) {
+		c := sp.src[sp.pos]
+
+		switch {
+		case c == '\\' && backslashEscapes:
+			sp.pos += 2
+		case c == closing && sp.peek(1) == closing:
+			sp.pos += 2
+		case c == closing:
+			sp.pos++
+
+			return nil
+		default:
+			sp.pos++
+		}
+	}
+
+	return fmt.Errorf("unterminated %c%c quote starting on line %d", open, closing, sp.line(start))
+}
+
+// isEscapeString reports whether the quote at the current position starts a PostgreSQL E'...' string.
+func (sp *splitter) isEscapeString() bool {
+	if !sp.rules.escapeStrings || sp.pos == 0 || (sp.src[sp.pos-1] != 'E' && sp.src[sp.pos-1] != 'e') {
+		return false
+	}
+
+	return sp.pos == 1 || !isIdentChar(sp.src[sp.pos-2])
+}
+
+func (sp *splitter) isDashComment() bool {
+	if sp.peek(1) != '-' {
+		return false
+	}
+
+	if !sp.rules.dashCommentNeedsSpace {
+		return true
+	}
+
+	next := sp.peek(2)
+
+	return next == 0 || isSpace(next)
+}
+
+// skipLine skips to the start of the next line.
+func (sp *splitter) skipLine() {
+	end := strings.IndexByte(sp.src[sp.pos:], '\n')
+	if end < 0 {
+		sp.pos = len(sp.src)
+
+		return
+	}
+
+	sp.pos += end + 1
+}
+
+func (sp *splitter) skipBlockComment() error {
+	start := sp.pos
+
+	if sp.rules.executableComments && sp.peek(2) == '!' {
+		sp.content = true
+	}
+
+	sp.pos += 2
+	depth := 1
+
+	for sp.pos < len(sp.src) {
+		switch {
+		case sp.src[sp.pos] == '*' && sp.peek(1) == '/':
+			sp.pos += 2
+			depth--
+
+			if depth == 0 {
+				return nil
+			}
+		case sp.src[sp.pos] == '/' && sp.peek(1) == '*' && sp.rules.nestedComments:
+			sp.pos += 2
+			depth++
+		default:
+			sp.pos++
+		}
+	}
+
+	return fmt.Errorf("unterminated /* comment starting on line %d", sp.line(start))
+}
+
+// skipDollarQuoted skips a $tag$...$tag$ string. A $ that does not start such a string, like the one in a $1
+// parameter, is skipped on its own.
+func (sp *splitter) skipDollarQuoted() error {
+	start := sp.pos
+	sp.content = true
+
+	end := sp.pos + 1
+	for end < len(sp.src) && isIdentChar(sp.src[end]) && sp.src[end] != '$' {
+		end++
+	}
+
+	if end >= len(sp.src) || sp.src[end] != '$' || (end > sp.pos+1 && !isIdentStart(sp.src[sp.pos+1])) {
+		sp.pos++
+
+		return nil
+	}
+
+	tag := sp.src[sp.pos : end+1]
+
+	closing := strings.Index(sp.src[end+1:], tag)
+	if closing < 0 {
+		return fmt.Errorf("unterminated %s quote starting on line %d", tag, sp.line(start))
+	}
+
+	sp.pos = end + 1 + closing + len(tag)
+
+	return nil
+}
+
+// word handles the word at the current position.
+func (sp *splitter) word() error {
+	start := sp.pos
+	w := sp.readWord()
+
+	if sp.rules.delimiterDirective && !sp.content && strings.EqualFold(w, "DELIMITER") && sp.atLineStart(start) {
+		return sp.delimiterDirective(start)
+	}
+
+	if sp.rules.goBatches && strings.EqualFold(w, "GO") && sp.atLineStart(start) {
+		if handled, err := sp.goDirective(start); handled || err != nil {
+			return err
+		}
+	}
+
+	sp.keyword(w)
+
+	return nil
+}
+
+// delimiterDirective reads the new terminator from the rest of a DELIMITER line, and removes the line from the
+// statements.
+func (sp *splitter) delimiterDirective(start int) error {
+	end := strings.IndexByte(sp.src[sp.pos:], '\n')
+	if end < 0 {
+		end = len(sp.src)
+	} else {
+		end += sp.pos
+	}
+
+	fields := strings.Fields(sp.src[sp.pos:end])
+	if len(fields) == 0 || !isSpace(sp.src[sp.pos]) {
+		return fmt.Errorf("DELIMITER without a delimiter on line %d", sp.line(start))
+	}
+
+	sp.delimiter = fields[0]
+	sp.pos = end
+	sp.start = end
+
+	return nil
+}
+
+// goDirective ends the current batch at a GO line, and removes the line from the batches. A count after GO, as in
+// GO 3, repeats the batch. It returns false if the rest of the line is not a count or a comment, as the word is then
+// not a GO directive.
+func (sp *splitter) goDirective(start int) (bool, error) {
+	end := strings.IndexByte(sp.src[sp.pos:], '\n')
+	if end < 0 {
+		end = len(sp.src)
+	} else {
+		end += sp.pos
+	}
+
+	rest := sp.src[sp.pos:end]
+	if comment := strings.Index(rest, "--"); comment >= 0 {
+		rest = rest[:comment]
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) > 1 || (rest != "" && !isSpace(rest[0])) {
+		return false, nil
+	}
+
+	count := 1
+
+	if len(fields) == 1 {
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return false, nil //nolint:nilerr
+		}
+
+		if n < 1 {
+			return true, fmt.Errorf("GO with a count below 1 on line %d", sp.line(start))
+		}
+
+		count = n
+	}
+
+	before := len(sp.statements)
+	sp.emit(strings.LastIndexByte(sp.src[:start], '\n') + 1)
+
+	if len(sp.statements) > before {
+		for i := 1; i < count; i++ {
+			sp.statements = append(sp.statements, sp.statements[before])
+		}
+	}
+
+	sp.pos = end
+	sp.start = end
+
+	return true, nil
+}
+
+// atLineStart reports whether there is only whitespace between the start of the line and offset.
+func (sp *splitter) atLineStart(offset int) bool {
+	for i := offset - 1; i >= 0 && sp.src[i] != '\n'; i-- {
+		if sp.src[i] != ' ' && sp.src[i] != '\t' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (sp *splitter) readWord() string {
+	start := sp.pos
+	sp.pos++
+
+	// The terminator ends a word, so that e.g. END$$ is read as END when the terminator is $$.
+	for sp.pos < len(sp.src) && isIdentChar(sp.src[sp.pos]) && !strings.HasPrefix(sp.src[sp.pos:], sp.delimiter) {
+		sp.pos++
+	}
+
+	return sp.src[start:sp.pos]
+}
+
+// keyword keeps track of the words of the current statement, to know when a BEGIN ... END block starts and ends.
+func (sp *splitter) keyword(word string) {
+	sp.content = true
+	word = strings.ToUpper(word)
+
+	if len(sp.words) < maxLeadingWords {
+		sp.words = append(sp.words, word)
+	}
+
+	// With another terminator than semicolon, blocks are kept together by the terminator instead.
+	if sp.delimiter != ";" {
+		return
+	}
+
+	switch {
+	case sp.depth > 0 && (word == "BEGIN" || word == "CASE"):
+		sp.depth++
+	case sp.depth > 0 && word == "END":
+		sp.depth--
+	case word == "BEGIN" && sp.opensBlock():
+		sp.depth++
+	}
+}
+
+// opensBlock reports whether a BEGIN at the current position starts a block, as opposed to e.g. a transaction.
+func (sp *splitter) opensBlock() bool {
+	if sp.rules.triggerBlocks && len(sp.words) > 0 && sp.words[0] == "CREATE" {
+		for _, w := range sp.words[1:] {
+			if w == "TRIGGER" {
+				return true
+			}
+		}
+	}
+
+	if sp.rules.atomicBlocks {
+		rest := strings.TrimLeft(sp.src[sp.pos:], " \t\r\n")
+		if len(rest) >= len("ATOMIC") && strings.EqualFold(rest[:len("ATOMIC")], "ATOMIC") &&
+			(len(rest) == len("ATOMIC") || !isIdentChar(rest[len("ATOMIC")])) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
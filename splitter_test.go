@@ -0,0 +1,590 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDialect_SplitStatements(t *testing.T) {
+	generic := GenericDialect{}
+	postgres := PostgresDialect{}
+	mysql := MySQLDialect{}
+	sqlite := SQLiteDialect{}
+	sqlserver := SQLServerDialect{}
+	all := []Dialect{generic, postgres, mysql, sqlite}
+
+	tests := []struct {
+		name     string
+		dialects []Dialect
+		sql      string
+		want     []string
+	}{
+		// Files without any special constructs are split like strings.Split.
+		{
+			name:     "single statement",
+			dialects: all,
+			sql:      "create table test (id int)",
+			want:     []string{"create table test (id int)"},
+		},
+		{
+			name:     "single statement with semicolon",
+			dialects: all,
+			sql:      "create table test (id int);",
+			want:     []string{"create table test (id int)"},
+		},
+		{
+			name:     "multiple statements",
+			dialects: all,
+			sql:      "create table a (id int);\n\ncreate table b (id int);\n",
+			want:     []string{"create table a (id int)", "\n\ncreate table b (id int)"},
+		},
+		{
+			name:     "empty file",
+			dialects: all,
+			sql:      "",
+			want:     nil,
+		},
+		{
+			name:     "whitespace only",
+			dialects: all,
+			sql:      " \n\t\r\n",
+			want:     nil,
+		},
+		{
+			name:     "empty statements",
+			dialects: all,
+			sql:      ";;\n;select 1;;",
+			want:     []string{"select 1"},
+		},
+		{
+			name:     "no trailing semicolon",
+			dialects: all,
+			sql:      "select 1;\nselect 2",
+			want:     []string{"select 1", "\nselect 2"},
+		},
+
+		// Quotes.
+		{
+			name:     "semicolon in string",
+			dialects: all,
+			sql:      "insert into t values ('a;b');select 1;",
+			want:     []string{"insert into t values ('a;b')", "select 1"},
+		},
+		{
+			name:     "doubled quote in string",
+			dialects: all,
+			sql:      "insert into t values ('it''s;here');select 1;",
+			want:     []string{"insert into t values ('it''s;here')", "select 1"},
+		},
+		{
+			name:     "semicolon in quoted identifier",
+			dialects: all,
+			sql:      `create table "a;b" (id int);select 1;`,
+			want:     []string{`create table "a;b" (id int)`, "select 1"},
+		},
+		{
+			name:     "doubled quote in quoted identifier",
+			dialects: all,
+			sql:      `create table "a"";b" (id int);select 1;`,
+			want:     []string{`create table "a"";b" (id int)`, "select 1"},
+		},
+		{
+			name:     "empty string",
+			dialects: all,
+			sql:      "insert into t values ('');select 1;",
+			want:     []string{"insert into t values ('')", "select 1"},
+		},
+		{
+			name:     "comment markers in string",
+			dialects: all,
+			sql:      "insert into t values ('-- /* ;');select 1;",
+			want:     []string{"insert into t values ('-- /* ;')", "select 1"},
+		},
+		{
+			name:     "backslash escaped quote in mysql",
+			dialects: []Dialect{mysql},
+			sql:      `insert into t values ('a\';b');select 1;`,
+			want:     []string{`insert into t values ('a\';b')`, "select 1"},
+		},
+		{
+			name:     "backslash escaped quote in mysql double quoted string",
+			dialects: []Dialect{mysql},
+			sql:      `insert into t values ("a\";b");select 1;`,
+			want:     []string{`insert into t values ("a\";b")`, "select 1"},
+		},
+		{
+			name:     "backslash is not an escape in standard strings",
+			dialects: []Dialect{generic, postgres, sqlite},
+			sql:      `insert into t values ('a\');select 1;`,
+			want:     []string{`insert into t values ('a\')`, "select 1"},
+		},
+		{
+			name:     "postgres escape string",
+			dialects: []Dialect{postgres},
+			sql:      `insert into t values (E'a\';b');select 1;`,
+			want:     []string{`insert into t values (E'a\';b')`, "select 1"},
+		},
+		{
+			name:     "postgres lower case escape string",
+			dialects: []Dialect{postgres},
+			sql:      `insert into t values (e'a\';b');select 1;`,
+			want:     []string{`insert into t values (e'a\';b')`, "select 1"},
+		},
+		{
+			name:     "standard string in postgres",
+			dialects: []Dialect{postgres},
+			sql:      `select 'a\' as name;select 1;`,
+			want:     []string{`select 'a\' as name`, "select 1"},
+		},
+		{
+			name:     "backtick identifier",
+			dialects: []Dialect{mysql, sqlite},
+			sql:      "create table `a;b` (id int);select 1;",
+			want:     []string{"create table `a;b` (id int)", "select 1"},
+		},
+		{
+			name:     "bracket identifier",
+			dialects: []Dialect{sqlite},
+			sql:      "create table [a;b] (id int);select 1;",
+			want:     []string{"create table [a;b] (id int)", "select 1"},
+		},
+
+		// Comments.
+		{
+			name:     "semicolon in line comment",
+			dialects: all,
+			sql:      "select 1 -- one; two\n;select 2;",
+			want:     []string{"select 1 -- one; two\n", "select 2"},
+		},
+		{
+			name:     "semicolon in block comment",
+			dialects: all,
+			sql:      "select 1 /* one; two */;select 2;",
+			want:     []string{"select 1 /* one; two */", "select 2"},
+		},
+		{
+			name:     "multi line block comment",
+			dialects: all,
+			sql:      "/*\n * a;\n * b;\n */\nselect 1;",
+			want:     []string{"/*\n * a;\n * b;\n */\nselect 1"},
+		},
+		{
+			name:     "quote in comment",
+			dialects: all,
+			sql:      "-- it's here\nselect 1;\n/* don't */select 2;",
+			want:     []string{"-- it's here\nselect 1", "\n/* don't */select 2"},
+		},
+		{
+			name:     "comment only statements are left out",
+			dialects: all,
+			sql:      "select 1;\n-- the end\n",
+			want:     []string{"select 1"},
+		},
+		{
+			name:     "comment only file",
+			dialects: all,
+			sql:      "/* nothing; here */\n-- nor; here",
+			want:     nil,
+		},
+		{
+			name:     "line comment at end of file",
+			dialects: all,
+			sql:      "select 1; -- done",
+			want:     []string{"select 1"},
+		},
+		{
+			name:     "nested block comment in postgres",
+			dialects: []Dialect{postgres},
+			sql:      "/* a /* b; */ c; */select 1;select 2;",
+			want:     []string{"/* a /* b; */ c; */select 1", "select 2"},
+		},
+		{
+			name:     "block comments do not nest outside postgres",
+			dialects: []Dialect{generic, mysql, sqlite},
+			sql:      "/* a /* b */ select 1;select 2;",
+			want:     []string{"/* a /* b */ select 1", "select 2"},
+		},
+		{
+			name:     "hash comment in mysql",
+			dialects: []Dialect{mysql},
+			sql:      "select 1 # one; two\n;select 2;",
+			want:     []string{"select 1 # one; two\n", "select 2"},
+		},
+		{
+			name:     "double dash without space is not a comment in mysql",
+			dialects: []Dialect{mysql},
+			sql:      "select 1--1;select 2;",
+			want:     []string{"select 1--1", "select 2"},
+		},
+		{
+			name:     "double dash at end of line is a comment in mysql",
+			dialects: []Dialect{mysql},
+			sql:      "select 1 --\n;select 2;",
+			want:     []string{"select 1 --\n", "select 2"},
+		},
+		{
+			name:     "executable comment in mysql",
+			dialects: []Dialect{mysql},
+			sql:      "/*!40101 SET NAMES utf8 */;\nselect 1;",
+			want:     []string{"/*!40101 SET NAMES utf8 */", "\nselect 1"},
+		},
+
+		// Dollar quotes.
+		{
+			name:     "dollar quoted function body",
+			dialects: []Dialect{generic, postgres},
+			sql: "create function f() returns int as $$\nbegin\n  perform 1;\n  return 1;\nend;\n$$ language plpgsql;\n" +
+				"select f();",
+			want: []string{
+				"create function f() returns int as $$\nbegin\n  perform 1;\n  return 1;\nend;\n$$ language plpgsql",
+				"\nselect f()",
+			},
+		},
+		{
+			name:     "tagged dollar quote",
+			dialects: []Dialect{generic, postgres},
+			sql:      "do $body$ begin perform '$$;'; end $body$;select 1;",
+			want:     []string{"do $body$ begin perform '$$;'; end $body$", "select 1"},
+		},
+		{
+			name:     "nested dollar quotes with different tags",
+			dialects: []Dialect{generic, postgres},
+			sql:      "select $a$ $b$ ; $b$ ; $a$;select 1;",
+			want:     []string{"select $a$ $b$ ; $b$ ; $a$", "select 1"},
+		},
+		{
+			name:     "positional parameter is not a dollar quote",
+			dialects: []Dialect{generic, postgres},
+			sql:      "prepare p as select $1;select $2;",
+			want:     []string{"prepare p as select $1", "select $2"},
+		},
+		{
+			name:     "dollar in identifier is not a dollar quote",
+			dialects: []Dialect{generic, postgres},
+			sql:      "select a$b$;select 1;",
+			want:     []string{"select a$b$", "select 1"},
+		},
+		{
+			name:     "dollar quotes are not special in mysql",
+			dialects: []Dialect{mysql, sqlite},
+			sql:      "select '$$';select $$;",
+			want:     []string{"select '$$'", "select $$"},
+		},
+
+		// Blocks.
+		{
+			name:     "sqlite trigger",
+			dialects: []Dialect{generic, sqlite},
+			sql: "create trigger t after insert on a\nbegin\n  insert into b values (new.id);\n  " +
+				"update c set n = n + 1;\nend;\ninsert into a values (1);",
+			want: []string{
+				"create trigger t after insert on a\nbegin\n  insert into b values (new.id);\n  update c set n = n + 1;\nend",
+				"\ninsert into a values (1)",
+			},
+		},
+		{
+			name:     "sqlite temporary trigger if not exists with case",
+			dialects: []Dialect{generic, sqlite},
+			sql: "CREATE TEMP TRIGGER IF NOT EXISTS t BEFORE UPDATE ON a BEGIN\n" +
+				"  SELECT CASE WHEN new.id < 0 THEN RAISE(ABORT, 'negative; id') END;\nEND;\nSELECT 1;",
+			want: []string{
+				"CREATE TEMP TRIGGER IF NOT EXISTS t BEFORE UPDATE ON a BEGIN\n" +
+					"  SELECT CASE WHEN new.id < 0 THEN RAISE(ABORT, 'negative; id') END;\nEND",
+				"\nSELECT 1",
+			},
+		},
+		{
+			name:     "begin transaction is not a block",
+			dialects: all,
+			sql:      "begin;\nselect 1;\ncommit;",
+			want:     []string{"begin", "\nselect 1", "\ncommit"},
+		},
+		{
+			name:     "begin in other statements is not a block",
+			dialects: all,
+			sql:      "create table begin_end (begin_at int);\nselect 1;",
+			want:     []string{"create table begin_end (begin_at int)", "\nselect 1"},
+		},
+		{
+			name:     "trigger blocks are not special in postgres",
+			dialects: []Dialect{postgres},
+			sql:      "create trigger t after insert on a for each row execute function f();select 1;",
+			want:     []string{"create trigger t after insert on a for each row execute function f()", "select 1"},
+		},
+		{
+			name:     "postgres begin atomic",
+			dialects: []Dialect{postgres},
+			sql: "create function f() returns int language sql\nbegin atomic\n  select 1;\n  " +
+				"select case when true then 2 end;\nend;\nselect f();",
+			want: []string{
+				"create function f() returns int language sql\nbegin atomic\n  select 1;\n  " +
+					"select case when true then 2 end;\nend",
+				"\nselect f()",
+			},
+		},
+
+		// DELIMITER directives.
+		{
+			name:     "mysql procedure with delimiter",
+			dialects: []Dialect{generic, mysql},
+			sql: "DELIMITER $$\nCREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND$$\nDELIMITER ;\n" +
+				"CALL p();\n",
+			want: []string{
+				"\nCREATE PROCEDURE p()\nBEGIN\n  SELECT 1;\n  SELECT 2;\nEND",
+				"\nCALL p()",
+			},
+		},
+		{
+			name:     "mysql trigger with delimiter",
+			dialects: []Dialect{generic, mysql},
+			sql: "create table a (id int);\ndelimiter //\ncreate trigger t before insert on a for each row\n" +
+				"begin\n  if new.id < 0 then set new.id = 0; end if;\nend //\ndelimiter ;\ninsert into a values (1);",
+			want: []string{
+				"create table a (id int)",
+				"\ncreate trigger t before insert on a for each row\n" +
+					"begin\n  if new.id < 0 then set new.id = 0; end if;\nend ",
+				"\ninsert into a values (1)",
+			},
+		},
+		{
+			name:     "several statements with changed delimiter",
+			dialects: []Dialect{generic, mysql},
+			sql:      "DELIMITER //\nselect 1; select 2//\nselect 3//",
+			want:     []string{"\nselect 1; select 2", "\nselect 3"},
+		},
+		{
+			name:     "delimiter in string and comment",
+			dialects: []Dialect{generic, mysql},
+			sql:      "DELIMITER //\nselect '//' -- //\n//\nDELIMITER ;\nselect 1;",
+			want:     []string{"\nselect '//' -- //\n", "\nselect 1"},
+		},
+		{
+			name:     "indented delimiter directive",
+			dialects: []Dialect{generic, mysql},
+			sql:      "  DELIMITER $$  \nselect 1$$\n\tdelimiter ;\nselect 2;",
+			want:     []string{"\nselect 1", "\nselect 2"},
+		},
+		{
+			name:     "delimiter after comment",
+			dialects: []Dialect{generic, mysql},
+			sql:      "-- procedures\nDELIMITER $$\nselect 1$$",
+			want:     []string{"\nselect 1"},
+		},
+		{
+			name:     "delimiter column is not a directive",
+			dialects: []Dialect{generic, mysql},
+			sql:      "create table t (\n  delimiter varchar(10)\n);\nselect 1;",
+			want:     []string{"create table t (\n  delimiter varchar(10)\n)", "\nselect 1"},
+		},
+		{
+			name:     "delimiter is not special in postgres",
+			dialects: []Dialect{postgres, sqlite},
+			sql:      "select 1;\ndelimiter //;",
+			want:     []string{"select 1", "\ndelimiter //"},
+		},
+
+		// Miscellaneous.
+		{
+			name:     "multi byte characters",
+			dialects: all,
+			sql:      "insert into t values ('åäö;ü');select 'ß';",
+			want:     []string{"insert into t values ('åäö;ü')", "select 'ß'"},
+		},
+		{
+			name:     "windows line endings",
+			dialects: all,
+			sql:      "select 1;\r\n-- comment;\r\nselect 2;\r\n",
+			want:     []string{"select 1", "\r\n-- comment;\r\nselect 2"},
+		},
+
+		// SQL Server files are split into batches on GO lines.
+		{
+			name:     "batch without GO",
+			dialects: []Dialect{sqlserver},
+			sql:      "create table a (id int);\ncreate table b (id int);\n",
+			want:     []string{"create table a (id int);\ncreate table b (id int);\n"},
+		},
+		{
+			name:     "GO batches",
+			dialects: []Dialect{sqlserver},
+			sql:      "create table a (id int);\nGO\ncreate procedure p as select 1;\n  go  -- end of p\nGO\n",
+			want:     []string{"create table a (id int);\n", "\ncreate procedure p as select 1;\n"},
+		},
+		{
+			name:     "GO with count",
+			dialects: []Dialect{sqlserver},
+			sql:      "insert into a values (1);\nGO 3\nselect 1",
+			want: []string{
+				"insert into a values (1);\n", "insert into a values (1);\n", "insert into a values (1);\n", "\nselect 1",
+			},
+		},
+		{
+			name:     "GO inside quotes, comments and words",
+			dialects: []Dialect{sqlserver},
+			sql:      "select 'a\nGO\n' as [x\nGO\n];\n/* /*\nGO\n*/ */\nselect 1 as go\nGO;\ngoto done\ndone:\n",
+			want: []string{
+				"select 'a\nGO\n' as [x\nGO\n];\n/* /*\nGO\n*/ */\nselect 1 as go\nGO;\ngoto done\ndone:\n",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, d := range tt.dialects {
+			t.Run(d.Name()+" "+tt.name, func(t *testing.T) {
+				got, err := d.SplitStatements(tt.sql)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				var sqls []string
+
+				for _, stmt := range got {
+					sqls = append(sqls, stmt.SQL)
+					assert.Equal(t, stmt.SQL, tt.sql[stmt.Offset:stmt.Offset+len(stmt.SQL)], "offset of %q", stmt.SQL)
+				}
+
+				assert.Equal(t, tt.want, sqls)
+			})
+		}
+	}
+}
+
+func TestDialect_SplitStatements_Unterminated(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect Dialect
+		sql     string
+		wantErr string
+	}{
+		{
+			name:    "string",
+			dialect: GenericDialect{},
+			sql:     "select 1;\nselect 'abc;",
+			wantErr: "unterminated '' quote starting on line 2",
+		},
+		{
+			name:    "quoted identifier",
+			dialect: GenericDialect{},
+			sql:     `select "abc;`,
+			wantErr: `unterminated "" quote starting on line 1`,
+		},
+		{
+			name:    "backslash escaped quote",
+			dialect: MySQLDialect{},
+			sql:     `select 'abc\';`,
+			wantErr: "unterminated '' quote starting on line 1",
+		},
+		{
+			name:    "bracket identifier",
+			dialect: SQLiteDialect{},
+			sql:     "select [abc;",
+			wantErr: "unterminated [] quote starting on line 1",
+		},
+		{
+			name:    "block comment",
+			dialect: GenericDialect{},
+			sql:     "select 1;\n\n/* abc;",
+			wantErr: "unterminated /* comment starting on line 3",
+		},
+		{
+			name:    "nested block comment",
+			dialect: PostgresDialect{},
+			sql:     "/* /* */ select 1;",
+			wantErr: "unterminated /* comment starting on line 1",
+		},
+		{
+			name:    "delimiter without delimiter",
+			dialect: MySQLDialect{},
+			sql:     "select 1;\nDELIMITER\nselect 2;",
+			wantErr: "DELIMITER without a delimiter on line 2",
+		},
+		{
+			name:    "dollar quote",
+			dialect: PostgresDialect{},
+			sql:     "do $fn$ begin end; $$;",
+			wantErr: "unterminated $fn$ quote starting on line 1",
+		},
+		{
+			name:    "GO with zero count",
+			dialect: SQLServerDialect{},
+			sql:     "select 1\nGO 0\n",
+			wantErr: "GO with a count below 1 on line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.dialect.SplitStatements(tt.sql)
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestService_Migrate_SQLiteTrigger(t *testing.T) {
+	db := openTestDB(t)
+
+	s := newTestService(t, db, fstest.MapFS{
+		"migrations/1.sql": {Data: []byte(`
+create table item (id int, name text);
+create table item_log (id int, note text);
+
+-- Log every insert; the trigger body has several statements.
+create trigger item_insert after insert on item
+begin
+    insert into item_log (id, note) values (new.id, 'inserted; ' || new.name);
+    insert into item_log (id, note) values (new.id, 'done');
+end;
+
+insert into item (id, name) values (1, 'first');
+`)},
+	}, DialectOption{Dialect: SQLiteDialect{}})
+
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	var notes []string
+
+	rows, err := db.Query("select note from item_log order by rowid")
+	if assert.NoError(t, err) {
+		for rows.Next() {
+			var note string
+			assert.NoError(t, rows.Scan(&note))
+			notes = append(notes, note)
+		}
+	}
+
+	assert.Equal(t, []string{"inserted; first", "done"}, notes)
+}
+
+func TestService_Migrate_Delimiter(t *testing.T) {
+	db := openTestDB(t)
+
+	// The same file works in the mysql client, and the directives are never sent to the database. The sqlite
+	// dialect that would be detected does not support DELIMITER, so the generic dialect is used.
+	s := newTestService(t, db, fstest.MapFS{
+		"migrations/1.sql": {Data: []byte(`
+create table item (id int);
+create table item_log (id int);
+
+DELIMITER $$
+create trigger item_insert after insert on item
+begin
+    insert into item_log (id) values (new.id);
+end$$
+DELIMITER ;
+
+insert into item (id) values (1);
+`)},
+	}, DialectOption{Dialect: GenericDialect{}})
+
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	count := 0
+	assert.NoError(t, db.QueryRow("select count(*) from item_log").Scan(&count))
+	assert.Equal(t, 1, count)
+}
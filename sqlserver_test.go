@@ -0,0 +1,261 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSQLServer is a database/sql driver that records the batches sent to it, to test the SQL sent to SQL Server
+// without one. Queries are answered by answer.
+type fakeSQLServer struct {
+	mu       sync.Mutex
+	executed []fakeBatch
+	answer   func(query string) ([]string, [][]driver.Value)
+}
+
+type fakeBatch struct {
+	SQL  string
+	Args []driver.Value
+}
+
+func (f *fakeSQLServer) Open(string) (driver.Conn, error) {
+	return &fakeConn{f: f}, nil
+}
+
+func (f *fakeSQLServer) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{f: f}, nil
+}
+
+func (f *fakeSQLServer) Driver() driver.Driver {
+	return f
+}
+
+func (f *fakeSQLServer) record(query string, args []driver.NamedValue) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	b := fakeBatch{SQL: query}
+	for _, a := range args {
+		b.Args = append(b.Args, a.Value)
+	}
+
+	f.executed = append(f.executed, b)
+}
+
+// batches returns the SQL of the recorded batches.
+func (f *fakeSQLServer) batches() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var sqls []string
+	for _, b := range f.executed {
+		sqls = append(sqls, b.SQL)
+	}
+
+	return sqls
+}
+
+type fakeConn struct {
+	f *fakeSQLServer
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.f.record("begin", nil)
+
+	return fakeTx{f: c.f}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.f.record(query, args)
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.f.record(query, args)
+
+	columns, rows := c.f.answer(query)
+
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+type fakeTx struct {
+	f *fakeSQLServer
+}
+
+func (tx fakeTx) Commit() error {
+	tx.f.record("commit", nil)
+
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.f.record("rollback", nil)
+
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
+
+// newFakeSQLServer returns a fake SQL Server on which the migration tables exist and are empty, and sp_getapplock
+// returns getAppLock.
+func newFakeSQLServer(t *testing.T, getAppLock int64) (*fakeSQLServer, *sql.DB) {
+	t.Helper()
+
+	f := &fakeSQLServer{answer: func(query string) ([]string, [][]driver.Value) {
+		switch {
+		case strings.HasPrefix(query, "select count(*)"):
+			return []string{"count"}, [][]driver.Value{{int64(1)}}
+		case strings.HasPrefix(query, "select * from [migration]"):
+			return []string{"id", "date", "checksum", "variant", "baselined"}, nil
+		case strings.HasPrefix(query, "declare @result int"):
+			return []string{"result"}, [][]driver.Value{{getAppLock}}
+		}
+
+		return nil, nil
+	}}
+
+	db := sql.OpenDB(f)
+	t.Cleanup(func() { _ = db.Close() })
+
+	return f, db
+}
+
+func TestService_Migrate_SQLServer(t *testing.T) {
+	f, db := newFakeSQLServer(t, 0)
+	s := newTestService(t, db, fstest.MapFS{
+		"migrations/1-users.sql": {Data: []byte("create table users (id int);\n" +
+			"create index users_id on users (id);\n" +
+			"GO\n" +
+			"create procedure touch_users as\n" +
+			"begin\n" +
+			"    update users set id = id;\n" +
+			"end\n" +
+			"GO\n")},
+	}, DialectOption{Dialect: SQLServerDialect{}})
+
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	assert.Equal(t, []string{
+		"if object_id(N'[migration]', N'U') is null create table [migration] (\n" +
+			"\tid varchar(255) primary key,\n" +
+			"\tdate datetime2 default current_timestamp,\n" +
+			"\tchecksum varchar(255),\n" +
+			"\tvariant varchar(255),\n" +
+			"\tbaselined bit\n" +
+			")",
+		"select variant from [migration] where 1 = 0",
+		"select baselined from [migration] where 1 = 0",
+		"if object_id(N'[migration_lock]', N'U') is null create table [migration_lock] (\n" +
+			"\tid integer primary key,\n" +
+			"\tcreated_at datetime2 default current_timestamp,\n" +
+			"\towner varchar(255),\n" +
+			"\thostname varchar(255),\n" +
+			"\tpid integer,\n" +
+			"\theartbeat_at datetime2 null\n" +
+			")",
+		"select owner from [migration_lock] where 1 = 0",
+		"select hostname from [migration_lock] where 1 = 0",
+		"select pid from [migration_lock] where 1 = 0",
+		"select heartbeat_at from [migration_lock] where 1 = 0",
+		"delete from [migration_lock] where coalesce(heartbeat_at, created_at) < dateadd(minute, -15, current_timestamp)",
+		"insert into [migration_lock](id, owner, hostname, pid, heartbeat_at) values(1, @p1, @p2, @p3, current_timestamp)",
+		"select * from [migration]",
+		"begin",
+		"create table users (id int);\ncreate index users_id on users (id);\n",
+		"\ncreate procedure touch_users as\nbegin\n    update users set id = id;\nend\n",
+		"insert into [migration] (id, checksum, variant) values (@p1, @p2, @p3)",
+		"commit",
+		"delete from [migration_lock] where id = 1 and owner = @p1",
+	}, f.batches())
+
+	insert := f.executed[len(f.executed)-3]
+	assert.Equal(t, []driver.Value{"1-users.sql", "91ce4ae290766f727177c46d21a2e4a0", nil}, insert.Args)
+}
+
+func TestSQLServerLocker(t *testing.T) {
+	tests := []struct {
+		name       string
+		getAppLock int64
+		wantLocked bool
+		wantErr    string
+	}{
+		{name: "granted", getAppLock: 0, wantLocked: true},
+		{name: "granted after waiting", getAppLock: 1, wantLocked: true},
+		{name: "held by another session", getAppLock: -1},
+		{
+			name: "deadlock", getAppLock: -3,
+			wantErr: "failed to get application lock mig.migration: sp_getapplock returned -3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, db := newFakeSQLServer(t, tt.getAppLock)
+			locker := NewSQLServerLocker(db, "mig.migration")
+
+			locked, err := locker.TryLock(context.Background())
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantLocked, locked)
+			assert.NoError(t, locker.Unlock(context.Background()))
+
+			want := []string{"declare @result int;\nexec @result = sp_getapplock @Resource = @p1, @LockMode = 'Exclusive', " +
+				"@LockOwner = 'Session', @LockTimeout = 0;\nselect @result"}
+			if tt.wantLocked {
+				want = append(want, "exec sp_releaseapplock @Resource = @p1, @LockOwner = 'Session'")
+			}
+
+			assert.Equal(t, want, f.batches())
+
+			for _, b := range f.executed {
+				assert.Equal(t, []driver.Value{"mig.migration"}, b.Args)
+			}
+		})
+	}
+}
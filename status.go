@@ -0,0 +1,155 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MigrationState is the state of a migration, as reported by Status.
+type MigrationState string
+
+const (
+	// StateApplied is a migration that has been applied, and has not changed since.
+	StateApplied MigrationState = "applied"
+
+	// StateModified is a migration that has been applied, but has changed since. Migrate fails on such migrations.
+	StateModified MigrationState = "modified"
+
+	// StatePending is a migration that has not been applied yet, or a repeatable migration that has changed since it
+	// was applied.
+	StatePending MigrationState = "pending"
+
+	// StateMissing is a migration that has been applied, but is no longer in the migration folder.
+	StateMissing MigrationState = "missing"
+
+	// StateIgnored is a file in the migration folder that Migrate will not apply, such as a .go file without a
+	// matching FuncMigration.
+	StateIgnored MigrationState = "ignored"
+)
+
+// MigrationStatus is the status of a single migration, as reported by Status.
+type MigrationStatus struct {
+	// ID is the unique ID of the migration, which is also its filename.
+	ID string
+
+	// Variant is the dialect of the variant in the migration folder, or empty if the file is not a variant.
+	Variant string
+
+	// AppliedVariant is the dialect of the variant that was applied, or empty if the applied file was not a variant.
+	AppliedVariant string
+
+	// Repeatable is set for repeatable migrations, which are applied again whenever they change.
+	Repeatable bool
+
+	// State is the state of the migration.
+	State MigrationState
+
+	// Checksum is the checksum of the migration in the migration folder.
+	// It is empty for missing and ignored migrations.
+	Checksum string
+
+	// AppliedChecksum is the checksum stored in the migration table when the migration was applied.
+	// It is empty for pending and ignored migrations.
+	AppliedChecksum string
+
+	// Baselined is set for migrations that were recorded by Baseline, without being applied.
+	Baselined bool
+
+	// AppliedAt is the date stored in the migration table when the migration was applied.
+	// It is the zero time for pending and ignored migrations.
+	AppliedAt time.Time
+}
+
+// Status compares the migration table with the migration folder, and returns the state of every migration found in
+// either of them, ordered by ID. Unlike Migrate, it does not stop at the first modified migration.
+// Status does not take the lock and does not create the migration tables.
+func (s *Service) Status() ([]MigrationStatus, error) {
+	return s.status(context.Background())
+}
+
+func (s *Service) status(ctx context.Context) ([]MigrationStatus, error) {
+	appliedMigs, err := s.fetchAppliedMigrationsIfExists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := s.listMigrations(appliedMigs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list available migrations: %w", err)
+	}
+
+	statuses := make([]MigrationStatus, 0, len(files))
+
+	for mig, file := range files {
+		st := MigrationStatus{ID: mig, Repeatable: s.isRepeatable(mig)}
+		st.Variant = s.variantOf(mig, file)
+
+		if appliedMig, applied := appliedMigs[mig]; applied {
+			st.AppliedChecksum = appliedMig.Checksum
+			st.AppliedVariant = appliedMig.Variant
+			st.Baselined = appliedMig.Baselined
+			st.AppliedAt = appliedMig.Date
+
+			st.Checksum, err = s.migrationChecksum(file)
+			if err != nil {
+				return nil, err
+			}
+
+			switch {
+			case st.Checksum == st.AppliedChecksum:
+				st.State = StateApplied
+			case st.Repeatable:
+				// Changed repeatable migrations are applied again by Migrate.
+				st.State = StatePending
+			default:
+				st.State = StateModified
+			}
+
+			statuses = append(statuses, st)
+
+			continue
+		}
+
+		funcMigration, err := s.findFuncMigration(mig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine if func migration should be applied: %w", err)
+		}
+
+		if funcMigration == nil && !isSQLMigration(mig) {
+			st.State = StateIgnored
+			statuses = append(statuses, st)
+
+			continue
+		}
+
+		st.Checksum, err = s.migrationChecksum(file)
+		if err != nil {
+			return nil, err
+		}
+
+		st.State = StatePending
+		statuses = append(statuses, st)
+	}
+
+	for id, appliedMig := range appliedMigs {
+		if _, available := files[id]; available {
+			continue
+		}
+
+		statuses = append(statuses, MigrationStatus{
+			ID:              id,
+			AppliedVariant:  appliedMig.Variant,
+			Repeatable:      s.isRepeatable(id),
+			Baselined:       appliedMig.Baselined,
+			State:           StateMissing,
+			AppliedChecksum: appliedMig.Checksum,
+			AppliedAt:       appliedMig.Date,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+
+	return statuses, nil
+}
@@ -0,0 +1,104 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestService_Status(t *testing.T) {
+	db := openTestDB(t)
+
+	applied := fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table first (id int);")},
+		"migrations/2.sql": {Data: []byte("create table second (id int);")},
+		"migrations/3.sql": {Data: []byte("create table third (id int);")},
+	}
+
+	err := newTestService(t, db, applied).Migrate()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	current := fstest.MapFS{
+		"migrations/1.sql": applied["migrations/1.sql"],
+		"migrations/2.sql": {Data: []byte("create table second (id int, name text);")},
+		"migrations/4.go":  {Data: []byte("package migrations")},
+		"migrations/5.sql": {Data: []byte("create table fifth (id int);")},
+	}
+
+	s := newTestService(t, db, current)
+
+	statuses, err := s.Status()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if !assert.Len(t, statuses, 5) {
+		return
+	}
+
+	for _, st := range statuses {
+		if st.State == StateApplied || st.State == StateModified || st.State == StateMissing {
+			assert.False(t, st.AppliedAt.IsZero(), "%s should have an applied date", st.ID)
+		} else {
+			assert.True(t, st.AppliedAt.IsZero(), "%s should not have an applied date", st.ID)
+		}
+	}
+
+	assert.Equal(t, MigrationStatus{ID: "1.sql", State: StateApplied,
+		Checksum:        "de17e395bdb9e15558de7dbbd3b87df2",
+		AppliedChecksum: "de17e395bdb9e15558de7dbbd3b87df2",
+		AppliedAt:       statuses[0].AppliedAt,
+	}, statuses[0])
+	assert.Equal(t, MigrationStatus{ID: "2.sql", State: StateModified,
+		Checksum:        "2d8e45e58e5a97af5cc095550a0e95d3",
+		AppliedChecksum: "0c3915908bb1f1fcfc357a45004a086b",
+		AppliedAt:       statuses[1].AppliedAt,
+	}, statuses[1])
+	assert.Equal(t, MigrationStatus{ID: "3.sql", State: StateMissing,
+		AppliedChecksum: "b3b72e77ef5ccb5a7d8ca1a8655bc1df",
+		AppliedAt:       statuses[2].AppliedAt,
+	}, statuses[2])
+	assert.Equal(t, MigrationStatus{ID: "4.go", State: StateIgnored}, statuses[3])
+	assert.Equal(t, MigrationStatus{ID: "5.sql", State: StatePending,
+		Checksum: "26f50ccf19ac2cc672b983721ca4c159",
+	}, statuses[4])
+}
+
+func TestService_Status_NoMigrationTable(t *testing.T) {
+	db := openTestDB(t)
+
+	s := newTestService(t, db, fstest.MapFS{"migrations/1.sql": {Data: []byte("create table first (id int);")}})
+
+	statuses, err := s.Status()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []MigrationStatus{{ID: "1.sql", State: StatePending, Checksum: "de17e395bdb9e15558de7dbbd3b87df2"}},
+		statuses)
+
+	tables, err := getTableNames(s, Sqlite)
+	assert.NoError(t, err)
+	assert.Empty(t, tables)
+}
+
+func TestService_Status_DoesNotLogIgnoredFiles(t *testing.T) {
+	observedZapCore, observedLogs := observer.New(zap.InfoLevel)
+
+	s := newTestService(t, openTestDB(t), fstest.MapFS{
+		"migrations/1.sql":     {Data: []byte("create table first (id int);")},
+		"migrations/2_test.go": {Data: []byte("package migrations")},
+	}, ZapOption{Logger: zap.New(observedZapCore)})
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 2) {
+		assert.Equal(t, StateIgnored, statuses[1].State)
+	}
+
+	assert.Zero(t, observedLogs.Len())
+}
@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// templateSuffix is the suffix of SQL migrations that are rendered with text/template before they are applied.
+const templateSuffix = ".sql.tmpl"
+
+// TemplateOption sets the variables used to render SQL migration templates, which are files ending with .sql.tmpl.
+// Templates are rendered with text/template, so a variable is used like {{ .Schema }}. Using a variable that is not
+// set is an error.
+//
+// The checksum of a template is computed over the template itself, not the rendered SQL, so changing the variables
+// does not change the checksum.
+type TemplateOption struct {
+	Vars map[string]any
+}
+
+func (o TemplateOption) apply(service *Service) {
+	service.templateVars = o.Vars
+}
+
+// renderTemplate renders a SQL migration template with the configured variables.
+func (s *Service) renderTemplate(name string, src []byte) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	if err := tmpl.Execute(&buf, s.templateVars); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
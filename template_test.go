@@ -0,0 +1,80 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Migrate_Template(t *testing.T) {
+	migrations := fstest.MapFS{
+		"migrations/1.sql.tmpl": {Data: []byte(
+			"create table {{ .Prefix }}users (id int);\n" +
+				"{{ range .Roles }}insert into {{ $.Prefix }}users (id) values ({{ . }});\n{{ end }}")},
+		"migrations/2.sql": {Data: []byte("create table plain (id int);")},
+	}
+
+	db := openTestDB(t)
+	s := newTestService(t, db, migrations, TemplateOption{Vars: map[string]any{"Prefix": "dev_", "Roles": []int{1, 2}}})
+
+	plan, err := s.Plan()
+	if assert.NoError(t, err) && assert.Len(t, plan, 2) {
+		assert.Equal(t, PlannedMigration{
+			ID:       "1.sql.tmpl",
+			Kind:     KindSQL,
+			Checksum: "619882c0f007993ad6077fef8fe81cc4",
+			Statements: []string{"create table dev_users (id int)", "\ninsert into dev_users (id) values (1)",
+				"\ninsert into dev_users (id) values (2)"},
+		}, plan[0])
+	}
+
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	count := 0
+	assert.NoError(t, db.QueryRow("select count(*) from dev_users").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	// The checksum is that of the template, so other variables do not trip the checksum check.
+	err = newTestService(t, db, migrations, TemplateOption{Vars: map[string]any{"Prefix": "prod_"}}).Migrate()
+	assert.NoError(t, err)
+}
+
+func TestService_Migrate_TemplateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		tmpl    string
+		wantErr string
+	}{
+		{
+			name:    "missing variable",
+			tmpl:    "create table {{ .Missing }}users (id int);",
+			wantErr: `map has no entry for key "Missing"`,
+		},
+		{
+			name:    "invalid template",
+			tmpl:    "create table {{ .Prefix users (id int);",
+			wantErr: "failed to render template 1.sql.tmpl",
+		},
+		{
+			name: "failing statement",
+			tmpl: "{{ range .Tables }}\ncreate table {{ $.Prefix }}{{ . }} (id int);\n{{ end }}\nnot sql;",
+			// The position is in the rendered SQL, in which the range has added lines: not sql is on line 4 of the file.
+			wantErr: "1.sql.tmpl (rendered):6:1: failing statement 3 [not sql;]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			err := newTestService(t, db, fstest.MapFS{"migrations/1.sql.tmpl": {Data: []byte(tt.tmpl)}},
+				TemplateOption{Vars: map[string]any{"Prefix": "dev_", "Tables": []string{"users", "roles"}}}).Migrate()
+
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package migration
+
+import (
+	"context"
+)
+
+// Validate checks that every applied migration is unchanged, and that there are no pending migrations. It returns
+// a *ValidationError listing every discrepancy, not just the first one.
+// Validate does not take the lock, does not create the migration tables and does not apply anything, so it can be
+// used by instances that do not own the migrations to check that the database matches their migrations.
+func (s *Service) Validate() error {
+	return s.validate(context.Background())
+}
+
+func (s *Service) validate(ctx context.Context) error {
+	statuses, err := s.status(ctx)
+	if err != nil {
+		return err
+	}
+
+	verr := &ValidationError{}
+
+	for _, st := range statuses {
+		switch st.State {
+		case StateModified:
+			verr.Mismatches = append(verr.Mismatches,
+				&ChecksumMismatchError{ID: st.ID, Expected: st.AppliedChecksum, Actual: st.Checksum})
+		case StatePending:
+			verr.Pending = append(verr.Pending, st.ID)
+		case StateApplied, StateMissing, StateIgnored:
+		}
+	}
+
+	if len(verr.Mismatches) > 0 || len(verr.Pending) > 0 {
+		return verr
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package migration
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_Validate(t *testing.T) {
+	db := openTestDB(t)
+
+	applied := fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table first (id int);")},
+		"migrations/2.sql": {Data: []byte("create table second (id int);")},
+		"migrations/3.sql": {Data: []byte("create table third (id int);")},
+	}
+
+	s := newTestService(t, db, applied)
+
+	verr := &ValidationError{}
+	if assert.ErrorAs(t, s.Validate(), &verr) {
+		assert.Empty(t, verr.Mismatches)
+		assert.Equal(t, []string{"1.sql", "2.sql", "3.sql"}, verr.Pending)
+	}
+
+	tables, err := getTableNames(s, Sqlite)
+	assert.NoError(t, err)
+	assert.Empty(t, tables, "validate must not create the migration tables")
+
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	assert.NoError(t, s.Validate())
+
+	changed := fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table first (id int, name text);")},
+		"migrations/2.sql": applied["migrations/2.sql"],
+		"migrations/3.sql": {Data: []byte("create table third (id int, name text);")},
+		"migrations/4.sql": {Data: []byte("create table fourth (id int);")},
+	}
+
+	// Another instance holding the lock must not keep Validate from running.
+	_, err = db.Exec("insert into migration_lock (id) values (1)")
+	assert.NoError(t, err)
+
+	err = newTestService(t, db, changed).Validate()
+
+	if assert.ErrorAs(t, err, &verr) {
+		assert.Equal(t, []*ChecksumMismatchError{
+			{ID: "1.sql", Expected: "de17e395bdb9e15558de7dbbd3b87df2", Actual: "3fe26d175aad4d2b44e108b89b862416"},
+			{ID: "3.sql", Expected: "b3b72e77ef5ccb5a7d8ca1a8655bc1df", Actual: "61a2c2f0547663b2b6c14fcfe029f925"},
+		}, verr.Mismatches)
+		assert.Equal(t, []string{"4.sql"}, verr.Pending)
+	}
+
+	var mismatch *ChecksumMismatchError
+	if assert.ErrorAs(t, err, &mismatch) {
+		assert.Equal(t, "1.sql", mismatch.ID)
+	}
+}
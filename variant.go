@@ -0,0 +1,109 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// variantDialects are the names of the built-in dialects, which can be used to name dialect variants of a migration.
+var variantDialects = []string{"postgres", "mysql", "sqlite", "sqlserver"}
+
+// detectDialect returns the dialect of the driver behind db, or GenericDialect if the driver is not known.
+func detectDialect(db *sql.DB) Dialect {
+	if db == nil {
+		return GenericDialect{}
+	}
+
+	// The type names are compared so that the drivers do not have to be imported.
+	switch fmt.Sprintf("%T", db.Driver()) {
+	case "*stdlib.Driver", "*pq.Driver":
+		return PostgresDialect{}
+	case "*mysql.MySQLDriver":
+		return MySQLDialect{}
+	case "*sqlite3.SQLiteDriver", "*sqlite.Driver":
+		return SQLiteDialect{}
+	case "*mssql.Driver":
+		return SQLServerDialect{}
+	}
+
+	return GenericDialect{}
+}
+
+// splitVariant returns the ID of a file in the migration folder and the dialect it is a variant for. With
+// Config.DialectVariants, a variant has the name of a dialect before its .sql or .sql.tmpl suffix, like
+// 2024-01-01-users.postgres.sql, and its ID is the name without it, like 2024-01-01-users.sql. Other files are their
+// own ID, and are not variants.
+func (s *Service) splitVariant(name string) (id, variant string) {
+	if !s.dialectVariants {
+		return name, ""
+	}
+
+	for _, suffix := range []string{templateSuffix, ".sql"} {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		base := strings.TrimSuffix(name, suffix)
+		ext := path.Ext(base)
+		dialect := strings.TrimPrefix(ext, ".")
+
+		if dialect == "" || (!slices.Contains(variantDialects, dialect) && dialect != s.currentDialect().Name()) {
+			return name, ""
+		}
+
+		return strings.TrimSuffix(base, ext) + suffix, dialect
+	}
+
+	return name, ""
+}
+
+// variantOf returns the dialect of file, the file applied for the migration mig, or an empty string if it is not a
+// variant.
+func (s *Service) variantOf(mig, file string) string {
+	if id, variant := s.splitVariant(file); id == mig {
+		return variant
+	}
+
+	return ""
+}
+
+// selectVariants picks the file to apply for each migration, given the names of the files in the migration folder.
+// The variant for the current dialect is preferred over a file that is not a variant, and variants for other
+// dialects are ignored. A migration that only has variants for other dialects is an error, as it would otherwise be
+// silently left out.
+// A file that is recorded in the migration table under its full name was applied before dialect variants were
+// enabled, and stays a migration of its own, so that it is not applied again under another ID.
+func (s *Service) selectVariants(names []string, appliedMigs map[string]migration) (map[string]string, error) {
+	dialect := s.currentDialect().Name()
+	files := make(map[string]string, len(names))
+	otherDialects := make(map[string]bool)
+
+	for _, name := range names {
+		id, variant := s.splitVariant(name)
+		if _, applied := appliedMigs[name]; applied {
+			id, variant = name, ""
+		}
+
+		switch variant {
+		case "":
+			if _, ok := files[id]; !ok {
+				files[id] = name
+			}
+		case dialect:
+			files[id] = name
+		default:
+			otherDialects[id] = true
+		}
+	}
+
+	for id := range otherDialects {
+		if _, ok := files[id]; !ok {
+			return nil, fmt.Errorf("migration %s has no variant for the %s dialect", id, dialect)
+		}
+	}
+
+	return files, nil
+}
@@ -0,0 +1,175 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_splitVariant(t *testing.T) {
+	tests := []struct {
+		name        string
+		wantID      string
+		wantVariant string
+	}{
+		{name: "2024-01-01-users.postgres.sql", wantID: "2024-01-01-users.sql", wantVariant: "postgres"},
+		{name: "2024-01-01-users.mysql.sql.tmpl", wantID: "2024-01-01-users.sql.tmpl", wantVariant: "mysql"},
+		{name: "2024-01-01-users.sqlite.sql", wantID: "2024-01-01-users.sql", wantVariant: "sqlite"},
+		{name: "2024-01-01-users.custom.sql", wantID: "2024-01-01-users.custom.sql"},
+		{name: "2024-01-01-v1.2.sql", wantID: "2024-01-01-v1.2.sql"},
+		{name: "2024-01-01-users.sql", wantID: "2024-01-01-users.sql"},
+		{name: "2024-01-01-users.postgres.go", wantID: "2024-01-01-users.postgres.go"},
+	}
+
+	s := New(nil, Config{DialectVariants: true})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, variant := s.splitVariant(tt.name)
+			assert.Equal(t, tt.wantID, id)
+			assert.Equal(t, tt.wantVariant, variant)
+		})
+	}
+
+	id, variant := New(nil).splitVariant("2024-01-01-users.postgres.sql")
+	assert.Equal(t, "2024-01-01-users.postgres.sql", id, "variants are only split with Config.DialectVariants")
+	assert.Equal(t, "", variant)
+}
+
+func TestService_Migrate_Variants(t *testing.T) {
+	migrations := fstest.MapFS{
+		"migrations/1-users.postgres.sql": {Data: []byte("create table users (id serial primary key);")},
+		"migrations/1-users.mysql.sql":    {Data: []byte("create table users (id int auto_increment primary key);")},
+		"migrations/1-users.sqlite.sql":   {Data: []byte("create table users (id integer primary key autoincrement);")},
+		"migrations/2-roles.sql":          {Data: []byte("create table roles (id int);")},
+	}
+
+	db := openTestDB(t)
+	s := newTestService(t, db, migrations, Config{DialectVariants: true})
+
+	assert.Equal(t, SQLiteDialect{}, s.currentDialect())
+
+	res, err := s.MigrateWithResult(context.Background())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"1-users.sql", "2-roles.sql"}, res.Applied())
+	assert.Equal(t, "sqlite", res.Migrations[0].Variant)
+	assert.Equal(t, "", res.Migrations[1].Variant)
+
+	var variant *string
+	if assert.NoError(t, db.QueryRow("select variant from migration where id = '1-users.sql'").Scan(&variant)) &&
+		assert.NotNil(t, variant) {
+		assert.Equal(t, "sqlite", *variant)
+	}
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 2) {
+		assert.Equal(t, StateApplied, statuses[0].State)
+		assert.Equal(t, "sqlite", statuses[0].Variant)
+		assert.Equal(t, "sqlite", statuses[0].AppliedVariant)
+		assert.Equal(t, "", statuses[1].AppliedVariant)
+	}
+
+	// The variant for the dialect is preferred over the file that is not a variant.
+	s = newTestService(t, db, fstest.MapFS{
+		"migrations/1.sql":        {Data: []byte("this is not sql;")},
+		"migrations/1.sqlite.sql": {Data: []byte("create table other (id int);")},
+	}, Config{DialectVariants: true})
+
+	plan, err := s.Plan()
+	if assert.NoError(t, err) && assert.Len(t, plan, 1) {
+		assert.Equal(t, "1.sql", plan[0].ID)
+		assert.Equal(t, "sqlite", plan[0].Variant)
+		assert.Equal(t, []string{"create table other (id int)"}, plan[0].Statements)
+	}
+}
+
+func TestService_Migrate_MissingVariant(t *testing.T) {
+	s := newTestService(t, openTestDB(t), fstest.MapFS{
+		"migrations/1-users.postgres.sql": {Data: []byte("create table users (id serial primary key);")},
+	}, Config{DialectVariants: true})
+
+	assert.ErrorContains(t, s.Migrate(), "migration 1-users.sql has no variant for the sqlite dialect")
+}
+
+func TestService_Migrate_AddsVariantColumn(t *testing.T) {
+	db := openTestDB(t)
+
+	// A migration table created before variants were supported.
+	_, err := db.Exec(`create table migration (
+		id varchar(255) primary key,
+		date timestamp default current_timestamp,
+		checksum varchar(255));
+		insert into migration (id, checksum) values ('1.sql', 'e5e3dbfc5c1e1fb4e6ed0c0e2f3e3c5f')`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	s := newTestService(t, db, fstest.MapFS{
+		"migrations/2.sqlite.sql": {Data: []byte("create table users (id int);")},
+	}, Config{DialectVariants: true})
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 2) {
+		assert.Equal(t, StateMissing, statuses[0].State)
+		assert.Equal(t, StatePending, statuses[1].State)
+	}
+
+	if !assert.NoError(t, s.Migrate()) {
+		return
+	}
+
+	var variant string
+	assert.NoError(t, db.QueryRow("select variant from migration where id = '2.sql'").Scan(&variant))
+	assert.Equal(t, "sqlite", variant)
+}
+
+func TestService_Migrate_VariantNamesAppliedBefore(t *testing.T) {
+	db := openTestDB(t)
+
+	// A migration table from before variants, in which a file named like a variant was applied under its own name.
+	_, err := db.Exec(`create table users (id int);
+		create table migration (
+		id varchar(255) primary key,
+		date timestamp default current_timestamp,
+		checksum varchar(255));
+		insert into migration (id, checksum) values ('2020-01-01-init.mysql.sql', '9102907a4763a264a28937ce21187300')`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	migrations := fstest.MapFS{
+		"migrations/2020-01-01-init.mysql.sql": {Data: []byte("create table users (id int);")},
+	}
+
+	// Without DialectVariants, the file is a migration of its own, as before.
+	s := newTestService(t, db, migrations)
+
+	res, err := s.MigrateWithResult(context.Background())
+	if assert.NoError(t, err) {
+		assert.Empty(t, res.Applied())
+	}
+
+	// With DialectVariants, the file is still applied under its full name, and new variants are applied by ID.
+	migrations["migrations/2020-02-01-roles.sqlite.sql"] = &fstest.MapFile{Data: []byte("create table roles (id int);")}
+	migrations["migrations/2020-02-01-roles.mysql.sql"] = &fstest.MapFile{Data: []byte("create table roles (id int);")}
+	s = newTestService(t, db, migrations, Config{DialectVariants: true})
+
+	statuses, err := s.Status()
+	if assert.NoError(t, err) && assert.Len(t, statuses, 2) {
+		assert.Equal(t, MigrationStatus{ID: "2020-01-01-init.mysql.sql", State: StateApplied,
+			Checksum: "9102907a4763a264a28937ce21187300", AppliedChecksum: "9102907a4763a264a28937ce21187300",
+			AppliedAt: statuses[0].AppliedAt}, statuses[0])
+		assert.Equal(t, "2020-02-01-roles.sql", statuses[1].ID)
+		assert.Equal(t, StatePending, statuses[1].State)
+	}
+
+	res, err = s.MigrateWithResult(context.Background())
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"2020-02-01-roles.sql"}, res.Applied())
+	}
+}
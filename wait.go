@@ -0,0 +1,100 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WaitOption sets how WaitUntilApplied polls the database.
+type WaitOption struct {
+	// PollInterval is the time between two polls.
+	// Defaults to 2 seconds.
+	PollInterval time.Duration
+
+	// WaitForLock makes WaitUntilApplied also wait until the lock table is no longer locked, so that the instance
+	// migrating the database has finished, including repeatable migrations that have not changed.
+	WaitForLock bool
+}
+
+func (o WaitOption) apply(service *Service) {
+	service.wait = o
+}
+
+// WaitUntilApplied blocks until every migration in the migration folder has been applied by another instance, for
+// instances that should not migrate the database themselves. It polls the migration table, and the lock table if
+// WaitOption.WaitForLock is set, until ctx is done.
+// It returns a *ValidationError at once if an applied migration has changed, as waiting would then never end. Other
+// errors, such as the database not being reachable yet, are logged and polled again. If ctx is done first, an error
+// wrapping ctx.Err() is returned, telling what was still pending. WaitForLock cannot be used with a Locker.
+func (s *Service) WaitUntilApplied(ctx context.Context) error {
+	if s.wait.WaitForLock && s.locker != nil {
+		return fmt.Errorf("cannot wait for the migration lock: %w", ErrNoLockTable)
+	}
+
+	interval := s.wait.PollInterval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	last := "migrations"
+
+	for {
+		waitingFor, err := s.waitingFor(ctx)
+
+		var verr *ValidationError
+
+		switch {
+		case errors.As(err, &verr):
+			return verr
+		case err != nil && ctx.Err() != nil:
+			// The check was interrupted by ctx, so what was waited for before is still the best answer.
+			return fmt.Errorf("stopped waiting for %s: %w", last, ctx.Err())
+		case err != nil:
+			// The database may not be reachable yet, e.g. while it is starting.
+			s.logger.Warn(fmt.Sprintf("failed to check for applied migrations: %v", err))
+			waitingFor = fmt.Sprintf("migrations, the last check failed with: %v", err)
+		case waitingFor == "":
+			return nil
+		default:
+			s.logger.Info(fmt.Sprintf("waiting for %s", waitingFor))
+		}
+
+		last = waitingFor
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for %s: %w", waitingFor, ctx.Err())
+		case <-time.After(interval):
+		}
+	}
+}
+
+// waitingFor returns what WaitUntilApplied is waiting for, or an empty string if it is done.
+func (s *Service) waitingFor(ctx context.Context) (string, error) {
+	var verr *ValidationError
+
+	switch err := s.validate(ctx); {
+	case errors.As(err, &verr) && len(verr.Mismatches) > 0:
+		return "", verr
+	case errors.As(err, &verr):
+		return fmt.Sprintf("pending migrations %s", strings.Join(verr.Pending, ", ")), nil
+	case err != nil:
+		return "", err
+	}
+
+	if s.wait.WaitForLock {
+		info, err := s.lockStatus(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		if info.Held {
+			return fmt.Sprintf("migration lock held by %s (pid %d)", info.Hostname, info.PID), nil
+		}
+	}
+
+	return "", nil
+}
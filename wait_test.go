@@ -0,0 +1,88 @@
+package migration
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestService_WaitUntilApplied(t *testing.T) {
+	db := openTestDB(t)
+	migrations := fstest.MapFS{
+		"migrations/1.sql": {Data: []byte("create table users (id int);")},
+		"migrations/2.sql": {Data: []byte("create table roles (id int);")},
+	}
+
+	newService := func(opts ...Option) *Service {
+		return newTestService(t, db, migrations,
+			append([]Option{WaitOption{PollInterval: 10 * time.Millisecond}}, opts...)...)
+	}
+
+	follower := newService()
+
+	// Nothing has been applied yet.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := follower.WaitUntilApplied(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stopped waiting for pending migrations 1.sql, 2.sql")
+
+	// The migrations are applied by another instance while waiting.
+	migrated := make(chan struct{})
+
+	go func() {
+		defer close(migrated)
+
+		time.Sleep(50 * time.Millisecond)
+		assert.NoError(t, newService().Migrate())
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	assert.NoError(t, follower.WaitUntilApplied(ctx))
+
+	// The lock of the migrating instance may not have been released yet.
+	<-migrated
+
+	// The lock is held by another instance, which has not finished yet.
+	locker := &tableLocker{s: follower}
+
+	locked, err := locker.TryLock(context.Background())
+	if !assert.NoError(t, err) || !assert.True(t, locked) {
+		return
+	}
+
+	assert.NoError(t, follower.WaitUntilApplied(context.Background()), "the lock is ignored by default")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = newService(WaitOption{PollInterval: 10 * time.Millisecond, WaitForLock: true}).WaitUntilApplied(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "stopped waiting for migration lock held by")
+
+	assert.NoError(t, locker.Unlock(context.Background()))
+	assert.NoError(t, newService(WaitOption{WaitForLock: true}).WaitUntilApplied(context.Background()))
+
+	// A changed migration fails at once, as it would never be applied.
+	migrations["migrations/1.sql"] = &fstest.MapFile{Data: []byte("create table users (id int, name text);")}
+	migrations["migrations/9.sql"] = &fstest.MapFile{Data: []byte("create table later (id int);")}
+
+	var verr *ValidationError
+	if assert.ErrorAs(t, follower.WaitUntilApplied(context.Background()), &verr) && assert.Len(t, verr.Mismatches, 1) {
+		assert.Equal(t, "1.sql", verr.Mismatches[0].ID)
+		assert.Equal(t, []string{"9.sql"}, verr.Pending, "like the error of Validate")
+	}
+}
+
+func TestService_WaitUntilApplied_Locker(t *testing.T) {
+	s := newTestService(t, openTestDB(t), fstest.MapFS{}, LockerOption{Locker: failingLocker{}},
+		WaitOption{WaitForLock: true})
+
+	assert.ErrorIs(t, s.WaitUntilApplied(context.Background()), ErrNoLockTable)
+}